@@ -2,10 +2,12 @@ package gaper
 
 import (
 	"fmt"
+	"os"
 	"os/exec"
 	"path/filepath"
 	"runtime"
 	"strings"
+	"time"
 )
 
 // Builder is a interface for the build process
@@ -15,14 +17,48 @@ type Builder interface {
 }
 
 type builder struct {
+	name      string
 	dir       string
 	binary    string
 	wd        string
 	buildArgs []string
+	env       []string
+}
+
+// BuilderConfig defines the settings available for the builder
+type BuilderConfig struct {
+	Name             string
+	Dir              string
+	BinName          string
+	WorkingDirectory string
+	BuildArgs        []string
+	DisableWorkspace bool
+	// builds the binary without optimizations, so it can be debugged
+	Debug bool
+	// builds the binary with the race detector
+	Race bool
+	// builds the binary with coverage, which is written to GOCOVERDIR
+	Cover bool
+	// custom command building the program on Dir instead of go build (e.g. "make build"),
+	// which must write the binary on the working directory unless it is run by another command
+	Command []string
 }
 
 // NewBuilder creates a new builder
 func NewBuilder(dir string, bin string, wd string, buildArgs []string) Builder {
+	return NewBuilderWithConfig(BuilderConfig{
+		Dir:              dir,
+		BinName:          bin,
+		WorkingDirectory: wd,
+		BuildArgs:        buildArgs,
+	})
+}
+
+// NewBuilderWithConfig creates a new builder based on the given settings
+func NewBuilderWithConfig(cfg BuilderConfig) Builder {
+	bin := cfg.BinName
+	wd := cfg.WorkingDirectory
+
 	// resolve bin name by current folder name
 	if bin == "" {
 		bin = filepath.Base(wd)
@@ -36,7 +72,36 @@ func NewBuilder(dir string, bin string, wd string, buildArgs []string) Builder {
 		}
 	}
 
-	return &builder{dir: dir, binary: bin, wd: wd, buildArgs: buildArgs}
+	if len(cfg.Command) > 0 {
+		return &commandBuilder{
+			name:    cfg.Name,
+			dir:     cfg.Dir,
+			binary:  bin,
+			command: cfg.Command,
+		}
+	}
+
+	// the build args come last so they can still override the ones from the build modes
+	var buildArgs []string
+	if cfg.Debug {
+		buildArgs = append(buildArgs, debugBuildArgs...)
+	}
+	if cfg.Race {
+		buildArgs = append(buildArgs, "-race")
+	}
+	if cfg.Cover {
+		buildArgs = append(buildArgs, "-cover")
+	}
+	buildArgs = append(buildArgs, cfg.BuildArgs...)
+
+	return &builder{
+		name:      cfg.Name,
+		dir:       cfg.Dir,
+		binary:    bin,
+		wd:        wd,
+		buildArgs: buildArgs,
+		env:       workspaceEnv(cfg.Dir, cfg.DisableWorkspace),
+	}
 }
 
 // Binary returns its build binary's path
@@ -46,21 +111,70 @@ func (b *builder) Binary() string {
 
 // Build the Golang project set for this builder
 func (b *builder) Build() error {
-	logger.Info("Building program")
+	logger.Info("Building " + programName(b.name))
 	args := append([]string{"go", "build", "-o", filepath.Join(b.wd, b.binary)}, b.buildArgs...)
 	logger.Debug("Build command", args)
 
+	start := time.Now()
 	command := exec.Command(args[0], args[1:]...) // nolint gas
 	command.Dir = b.dir
+	if len(b.env) > 0 {
+		command.Env = append(os.Environ(), b.env...)
+	}
 
 	output, err := command.CombinedOutput()
 	if err != nil {
-		return fmt.Errorf("build failed with %v\n%s", err, output)
+		return newBuildError(b.name, err, output, b.dir)
 	}
 
 	if !command.ProcessState.Success() {
 		return fmt.Errorf("error building: %s", output)
 	}
 
+	logger.DebugWith("Built "+programName(b.name), Field("duration", time.Since(start)))
+	return nil
+}
+
+// commandBuilder builds the program with a custom command (e.g. "make build")
+type commandBuilder struct {
+	name    string
+	dir     string
+	binary  string
+	command []string
+}
+
+// Binary returns the path of the binary built by the command
+func (b *commandBuilder) Binary() string {
+	return b.binary
+}
+
+// Build runs the build command on the build path
+func (b *commandBuilder) Build() error {
+	logger.Info("Building " + programName(b.name))
+	logger.Debug("Build command", b.command)
+
+	start := time.Now()
+	command := exec.Command(b.command[0], b.command[1:]...) // nolint gas
+	command.Dir = b.dir
+
+	output, err := command.CombinedOutput()
+	if err != nil {
+		return newBuildError(b.name, err, output, b.dir)
+	}
+
+	logger.DebugWith("Built "+programName(b.name), Field("duration", time.Since(start)))
+	return nil
+}
+
+// noBuilder is used by the programs run by a command that don't need a build
+type noBuilder struct{}
+
+// Binary returns no binary as nothing is built
+func (b *noBuilder) Binary() string {
+	return ""
+}
+
+// Build does nothing
+func (b *noBuilder) Build() error {
 	return nil
 }
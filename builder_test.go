@@ -1,6 +1,7 @@
 package gaper
 
 import (
+	"io/ioutil"
 	"os"
 	"path/filepath"
 	"runtime"
@@ -47,6 +48,25 @@ func TestBuilderFailureBuild(t *testing.T) {
 		"./main.go:5:1: missing return\n")
 }
 
+func TestBuilderFailureDiagnostics(t *testing.T) {
+	dir := filepath.Join("testdata", "build-failure")
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("couldn't get current working directory: %v", err)
+	}
+
+	b := NewBuilder(dir, "srv", wd, nil)
+	err = b.Build()
+	assert.IsType(t, &BuildError{}, err)
+
+	file := filepath.Join(wd, dir, "main.go")
+	pkg := "github.com/maxcnunes/gaper/testdata/build-failure"
+	assert.Equal(t, []Diagnostic{
+		{Package: pkg, File: file, Line: 4, Column: 6, Message: "func main must have no arguments and no return values"},
+		{Package: pkg, File: file, Line: 5, Column: 1, Message: "missing return"},
+	}, err.(*BuildError).Diagnostics)
+}
+
 func TestBuilderDefaultBinName(t *testing.T) {
 	bin := ""
 	dir := filepath.Join("testdata", "server")
@@ -55,6 +75,65 @@ func TestBuilderDefaultBinName(t *testing.T) {
 	assert.Equal(t, b.Binary(), resolveBinNameByOS("project-name"))
 }
 
+func TestBuilderWorkspaceDisabled(t *testing.T) {
+	b := NewBuilderWithConfig(BuilderConfig{
+		Dir:              filepath.Join("testdata", "modules", "app"),
+		BinName:          "app",
+		DisableWorkspace: true,
+	})
+	assert.Equal(t, []string{"GOWORK=off"}, b.(*builder).env)
+}
+
+func TestBuilderDebug(t *testing.T) {
+	b := NewBuilderWithConfig(BuilderConfig{
+		Dir:       filepath.Join("testdata", "server"),
+		BinName:   "srv",
+		BuildArgs: []string{"-tags", "dev"},
+		Debug:     true,
+	})
+	assert.Equal(t, []string{"-gcflags=all=-N -l", "-tags", "dev"}, b.(*builder).buildArgs)
+}
+
+func TestBuilderRaceAndCover(t *testing.T) {
+	b := NewBuilderWithConfig(BuilderConfig{
+		Dir:       filepath.Join("testdata", "server"),
+		BinName:   "srv",
+		BuildArgs: []string{"-tags", "dev"},
+		Race:      true,
+		Cover:     true,
+	})
+	assert.Equal(t, []string{"-race", "-cover", "-tags", "dev"}, b.(*builder).buildArgs)
+}
+
+func TestBuilderCommand(t *testing.T) {
+	if runtime.GOOS == OSWindows {
+		t.Skip("sh is not available on windows")
+	}
+
+	dir, err := ioutil.TempDir("", "gaper-build")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir) // nolint errcheck
+
+	b := NewBuilderWithConfig(BuilderConfig{
+		Dir:     dir,
+		BinName: "app",
+		Command: []string{"sh", "-c", "echo built > app"},
+	})
+	assert.Nil(t, b.Build(), "build error")
+	assert.Equal(t, "app", b.Binary())
+
+	data, err := ioutil.ReadFile(filepath.Join(dir, "app"))
+	assert.Nil(t, err, "file written by the build command")
+	assert.Equal(t, "built\n", string(data))
+
+	b = NewBuilderWithConfig(BuilderConfig{Dir: dir, Command: []string{"sh", "-c", "echo broken; exit 3"}})
+	err = b.Build()
+	assert.NotNil(t, err, "build error")
+	assert.Equal(t, "build failed with exit status 3\nbroken\n", err.Error())
+}
+
 func resolveBinNameByOS(name string) string {
 	if runtime.GOOS == OSWindows {
 		name += ".exe"
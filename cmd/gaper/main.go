@@ -1,6 +1,7 @@
 package main
 
 import (
+	"errors"
 	"os"
 
 	"github.com/maxcnunes/gaper"
@@ -18,22 +19,156 @@ var (
 func main() {
 	logger := gaper.Logger()
 	loggerVerbose := false
+	programExitCode := 0
 
-	parseArgs := func(c *cli.Context) *gaper.Config {
+	// the exit of the program or command is already logged,
+	// gaper only exits with the same code
+	exitWith := func(err error) error {
+		var exitErr exitCoder
+		if errors.As(err, &exitErr) {
+			programExitCode = exitErr.ExitCode()
+			return nil
+		}
+
+		return err
+	}
+
+	parseArgs := func(c *cli.Context) (*gaper.Config, error) {
 		loggerVerbose = c.Bool("verbose")
 
-		return &gaper.Config{
-			BinName:              c.String("bin-name"),
-			BuildPath:            c.String("build-path"),
-			BuildArgsMerged:      c.String("build-args"),
-			ProgramArgsMerged:    c.String("program-args"),
-			DisableDefaultIgnore: c.Bool("disable-default-ignore"),
-			WatchItems:           c.StringSlice("watch"),
-			IgnoreItems:          c.StringSlice("ignore"),
-			PollInterval:         c.Int("poll-interval"),
-			Extensions:           c.StringSlice("extensions"),
-			NoRestartOn:          c.String("no-restart-on"),
+		cfg := &gaper.Config{}
+		configFile := c.String("config")
+		if configFile != "" {
+			var err error
+			if cfg, err = gaper.LoadConfigFile(configFile); err != nil {
+				return nil, err
+			}
+		}
+
+		// arguments from the command line have priority over the config file
+		useFlag := func(name string) bool {
+			return configFile == "" || c.IsSet(name)
+		}
+
+		if useFlag("bin-name") {
+			cfg.BinName = c.String("bin-name")
+		}
+		if useFlag("build-path") {
+			cfg.BuildPath = c.String("build-path")
+		}
+		if useFlag("build-args") {
+			cfg.BuildArgsMerged = c.String("build-args")
+		}
+		if useFlag("program-args") {
+			cfg.ProgramArgsMerged = c.String("program-args")
+		}
+		if useFlag("disable-default-ignore") {
+			cfg.DisableDefaultIgnore = c.Bool("disable-default-ignore")
+		}
+		if useFlag("disable-workspace") {
+			cfg.DisableWorkspace = c.Bool("disable-workspace")
+		}
+		if useFlag("disable-keyboard") {
+			cfg.DisableKeyboard = c.Bool("disable-keyboard")
+		}
+		if useFlag("control-addr") {
+			cfg.ControlAddr = c.String("control-addr")
+		}
+		if useFlag("pause-file") {
+			cfg.PauseFiles = c.StringSlice("pause-file")
+		}
+		if useFlag("disable-git-pause") {
+			cfg.DisableGitPause = c.Bool("disable-git-pause")
+		}
+		if useFlag("exit-with-program") {
+			cfg.ExitWithProgram = c.Bool("exit-with-program")
+		}
+		if useFlag("once") {
+			cfg.Once = c.Bool("once")
+		}
+		if useFlag("stdin") {
+			cfg.Stdin = c.Bool("stdin")
+		}
+		if useFlag("pty") {
+			cfg.PTY = c.Bool("pty")
+		}
+		if useFlag("escape-key") {
+			cfg.EscapeKey = c.String("escape-key")
+		}
+		if useFlag("forward-signal") {
+			cfg.ForwardSignals = c.StringSlice("forward-signal")
+		}
+		if useFlag("signal-process-group") {
+			cfg.SignalProcessGroup = c.Bool("signal-process-group")
+		}
+		if useFlag("restart-on-sighup") {
+			cfg.RestartOnSIGHUP = c.Bool("restart-on-sighup")
+		}
+		if useFlag("build-command") {
+			cfg.BuildCommandMerged = c.String("build-command")
+		}
+		if useFlag("run-command") {
+			cfg.RunCommandMerged = c.String("run-command")
+		}
+		if useFlag("exec-wrapper") {
+			cfg.ExecWrapperMerged = c.String("exec-wrapper")
+		}
+		if useFlag("debug") {
+			cfg.Debug = c.Bool("debug")
+		}
+		if useFlag("debug-addr") {
+			cfg.DebugAddr = c.String("debug-addr")
+		}
+		if useFlag("race") {
+			cfg.Race = c.Bool("race")
+		}
+		if useFlag("cover") {
+			cfg.Cover = c.Bool("cover")
+		}
+		if useFlag("cover-dir") {
+			cfg.CoverDir = c.String("cover-dir")
+		}
+		if useFlag("diagnostics-file") {
+			cfg.DiagnosticsFile = c.String("diagnostics-file")
+		}
+		if useFlag("diagnostics-format") {
+			cfg.DiagnosticsFormat = c.String("diagnostics-format")
+		}
+		if useFlag("watch") {
+			cfg.WatchItems = c.StringSlice("watch")
+		}
+		if useFlag("ignore") {
+			cfg.IgnoreItems = c.StringSlice("ignore")
+		}
+		if useFlag("poll-interval") {
+			cfg.PollInterval = c.Int("poll-interval")
 		}
+		if useFlag("extensions") {
+			cfg.Extensions = c.StringSlice("extensions")
+		}
+		if useFlag("no-restart-on") {
+			cfg.NoRestartOn = c.String("no-restart-on")
+		}
+		if useFlag("restart-policy") {
+			cfg.RestartPolicy = c.String("restart-policy")
+		}
+		if useFlag("output-prefix") {
+			cfg.OutputPrefix = c.String("output-prefix")
+		}
+		if useFlag("output-color") {
+			cfg.OutputColor = c.String("output-color")
+		}
+		if useFlag("output-timestamp") {
+			cfg.OutputTimestamp = c.Bool("output-timestamp")
+		}
+		if useFlag("output-log-dir") {
+			cfg.OutputLogDir = c.String("output-log-dir")
+		}
+		if useFlag("output-log-retention") {
+			cfg.OutputLogRetention = c.Int("output-log-retention")
+		}
+
+		return cfg, nil
 	}
 
 	app := cli.NewApp()
@@ -42,15 +177,62 @@ func main() {
 	app.Version = version
 
 	app.Action = func(c *cli.Context) error {
-		args := parseArgs(c)
+		args, err := parseArgs(c)
+		if err != nil {
+			return err
+		}
+
 		chOSSiginal := make(chan os.Signal, 2)
-		logger.Verbose(loggerVerbose)
+		if err := setupLogger(c, loggerVerbose); err != nil {
+			return err
+		}
 
-		return gaper.Run(args, chOSSiginal)
+		return exitWith(gaper.Run(args, chOSSiginal))
+	}
+
+	app.Commands = []*cli.Command{
+		{
+			Name:      "smoke",
+			Usage:     "builds and runs the program, waits for it to be ready and runs a command against it, exiting with the command exit code",
+			ArgsUsage: "-- <command> [arguments...]",
+			Flags: []cli.Flag{
+				&cli.StringFlag{
+					Name:  "ready",
+					Usage: "readiness probe checked before running the command (e.g. \"http://localhost:8080/health\" or \"tcp://localhost:5432\")",
+				},
+				&cli.IntFlag{
+					Name:  "ready-timeout",
+					Value: gaper.DefaultReadyTimeout,
+					Usage: "time in ms to wait for the program to be ready",
+				},
+			},
+			Action: func(c *cli.Context) error {
+				args, err := parseArgs(c)
+				if err != nil {
+					return err
+				}
+
+				chOSSiginal := make(chan os.Signal, 2)
+				if err := setupLogger(c, loggerVerbose); err != nil {
+					return err
+				}
+
+				return exitWith(gaper.Smoke(args, gaper.SmokeConfig{
+					Ready:        c.String("ready"),
+					ReadyTimeout: c.Int("ready-timeout"),
+					Command:      c.Args().Slice(),
+				}, chOSSiginal))
+			},
+		},
 	}
 
 	// supported arguments
 	app.Flags = []cli.Flag{
+		&cli.StringFlag{
+			Name:    "config",
+			Aliases: []string{"c"},
+			Usage:   "path to a YAML config file, arguments from the command line have priority over it",
+		},
 		&cli.StringFlag{
 			Name:  "bin-name",
 			Usage: "name for the binary built by gaper for the executed program (default current directory name)",
@@ -72,10 +254,133 @@ func main() {
 			Name:  "verbose",
 			Usage: "turns on the verbose messages from gaper",
 		},
+		&cli.StringFlag{
+			Name:  "log-format",
+			Value: gaper.LogFormatText,
+			Usage: "format of the messages from gaper: \"text\" or \"json\" (one JSON object per message)",
+		},
+		&cli.StringFlag{
+			Name:  "log-level",
+			Value: gaper.LogLevelInfo,
+			Usage: "minimum level of the messages from gaper: \"debug\", \"info\", \"warn\" or \"error\"",
+		},
+		&cli.BoolFlag{
+			Name:  "quiet",
+			Usage: "only prints error messages from gaper",
+		},
+		&cli.StringFlag{
+			Name:  "log-file",
+			Usage: "path to a file where the messages from gaper are also appended",
+		},
+		&cli.IntFlag{
+			Name:  "log-file-max-size",
+			Value: gaper.DefaultLogFileMaxSize,
+			Usage: "size in MB the log file can reach before being rotated, 0 disables the rotation",
+		},
+		&cli.IntFlag{
+			Name:  "log-file-max-backups",
+			Value: gaper.DefaultLogFileMaxBackups,
+			Usage: "number of rotated log files kept",
+		},
 		&cli.BoolFlag{
 			Name:  "disable-default-ignore",
 			Usage: "turns off default ignore for hidden files and folders, \"*_test.go\" files, and vendor folder",
 		},
+		&cli.BoolFlag{
+			Name:  "disable-workspace",
+			Usage: "turns off the go.work workspace mode on building and watching the program",
+		},
+		&cli.BoolFlag{
+			Name:  "disable-keyboard",
+			Usage: "turns off the keyboard commands read from the terminal",
+		},
+		&cli.StringFlag{
+			Name:  "control-addr",
+			Usage: "address of the HTTP control API on localhost (e.g. \"localhost:7070\") or a unix socket (e.g. \"unix:/tmp/gaper.sock\")",
+		},
+		&cli.StringSliceFlag{
+			Name:  "pause-file",
+			Usage: "list of files which pause the watching while they exist (e.g. \".git/index.lock\")",
+		},
+		&cli.BoolFlag{
+			Name:  "disable-git-pause",
+			Usage: "turns off the pause of the watching while a git operation (e.g. checkout, merge or rebase) is in progress",
+		},
+		&cli.BoolFlag{
+			Name:  "exit-with-program",
+			Usage: "exits with the program exit code once the program ends and is not restarted by the restart policy",
+		},
+		&cli.BoolFlag{
+			Name:  "once",
+			Usage: "builds and runs the program only once without watching for changes, exiting with the program exit code",
+		},
+		&cli.BoolFlag{
+			Name:  "stdin",
+			Usage: "forwards the input from gaper to the program",
+		},
+		&cli.BoolFlag{
+			Name:  "pty",
+			Usage: "runs the program attached to a pseudo-terminal, forwarding the input and the window size to it",
+		},
+		&cli.StringFlag{
+			Name:  "escape-key",
+			Value: gaper.DefaultEscapeKey,
+			Usage: "key pressed before a keyboard command while the input is forwarded to the program",
+		},
+		&cli.StringSliceFlag{
+			Name:  "forward-signal",
+			Usage: "list of signals received by gaper which are forwarded to the program without restarting it (e.g. \"SIGUSR1,SIGUSR2\")",
+		},
+		&cli.BoolFlag{
+			Name:  "signal-process-group",
+			Usage: "runs the program in its own process group and forwards the signals to the whole group",
+		},
+		&cli.BoolFlag{
+			Name:  "restart-on-sighup",
+			Usage: "rebuilds and restarts the program when gaper receives a SIGHUP",
+		},
+		&cli.StringFlag{
+			Name:  "build-command",
+			Usage: "command building the program instead of go build (e.g. \"make build\")",
+		},
+		&cli.StringFlag{
+			Name:  "run-command",
+			Usage: "command running the program instead of the binary built by gaper (e.g. \"python app.py\")",
+		},
+		&cli.StringFlag{
+			Name:  "exec-wrapper",
+			Usage: "command the program runs through (e.g. \"strace -f\" or \"taskset -c 0\")",
+		},
+		&cli.BoolFlag{
+			Name:  "debug",
+			Usage: "builds the program without optimizations and runs it under a headless delve debugger, restarted on every rebuild",
+		},
+		&cli.StringFlag{
+			Name:  "debug-addr",
+			Value: gaper.DefaultDebugAddr,
+			Usage: "address the debugger listens on in debug mode",
+		},
+		&cli.BoolFlag{
+			Name:  "race",
+			Usage: "builds the program with the race detector, counting the data races reported",
+		},
+		&cli.BoolFlag{
+			Name:  "cover",
+			Usage: "builds the program with coverage, merging the coverage from all runs into a report on exit",
+		},
+		&cli.StringFlag{
+			Name:  "cover-dir",
+			Value: gaper.DefaultCoverDir,
+			Usage: "directory where the coverage data and reports are written to",
+		},
+		&cli.StringFlag{
+			Name:  "diagnostics-file",
+			Usage: "file where the errors from the last build are written to, so editors can load them",
+		},
+		&cli.StringFlag{
+			Name:  "diagnostics-format",
+			Usage: "format of the diagnostics file: quickfix or json (default: json for \".json\" files, otherwise quickfix)",
+		},
 		&cli.StringSliceFlag{
 			Name:  "watch, w",
 			Usage: "list of folders or files to watch for changes",
@@ -97,15 +402,92 @@ func main() {
 		},
 		&cli.StringFlag{
 			Name: "no-restart-on, n",
-			Usage: "don't automatically restart the supervised program if it ends:\n" +
+			Usage: "don't automatically restart the supervised program if it ends (deprecated by --restart-policy):\n" +
 				"\t\tif \"error\", an exit code of 0 will still restart.\n" +
 				"\t\tif \"exit\", no restart regardless of exit code.\n" +
 				"\t\tif \"success\", no restart only if exit code is 0.",
 		},
+		&cli.StringFlag{
+			Name: "restart-policy",
+			Usage: "when to restart the supervised program if it ends, otherwise it waits for a file change:\n" +
+				"\t\tif \"always\", restart regardless of exit code (default).\n" +
+				"\t\tif \"on-failure\", restart only if exit code is not 0.\n" +
+				"\t\tif \"never\", no restart regardless of exit code.\n" +
+				"\t\tif \"unless-exit-codes:0,3\", restart unless exit code is one of the codes.\n" +
+				"\t\tif \"max-retries:5\", restart on failures up to 5 consecutive times.",
+		},
+		&cli.StringFlag{
+			Name:  "output-prefix",
+			Usage: "label prefixing each line of the program output, lines from stderr are also marked with \"err\"",
+		},
+		&cli.StringFlag{
+			Name:  "output-color",
+			Usage: "color of the output prefix label (e.g. green, yellow, blue, magenta, cyan, hi-green)",
+		},
+		&cli.BoolFlag{
+			Name:  "output-timestamp",
+			Usage: "prefixes each line of the program output with the time it was written",
+		},
+		&cli.StringFlag{
+			Name:  "output-log-dir",
+			Usage: "directory where the program output of each run is also written to (e.g. \".gaper/logs\")",
+		},
+		&cli.IntFlag{
+			Name:  "output-log-retention",
+			Value: gaper.DefaultOutputLogRetention,
+			Usage: "number of program output log files kept, a negative value keeps all files",
+		},
 	}
 
-	if err := app.Run(os.Args); err != nil {
+	err := app.Run(os.Args)
+	if err != nil {
 		logger.Errorf("Error running gaper: %v", err)
+	}
+
+	if errClose := logger.Close(); errClose != nil {
+		logger.Errorf("Error closing log file: %v", errClose)
+	}
+
+	if err != nil {
 		os.Exit(1)
 	}
+
+	if programExitCode != 0 {
+		os.Exit(programExitCode)
+	}
+}
+
+// exitCoder is implemented by the errors with the code gaper exits with
+type exitCoder interface {
+	error
+	ExitCode() int
+}
+
+// setupLogger applies the logger settings from the command line,
+// quiet and verbose have priority over the log level
+func setupLogger(c *cli.Context, verbose bool) error {
+	logger := gaper.Logger()
+
+	if err := logger.SetLevel(c.String("log-level")); err != nil {
+		return err
+	}
+
+	if verbose {
+		logger.Verbose(true)
+	}
+
+	if c.Bool("quiet") {
+		logger.Quiet(true)
+	}
+
+	if err := logger.SetFormat(c.String("log-format")); err != nil {
+		return err
+	}
+
+	if path := c.String("log-file"); path != "" {
+		maxSize := int64(c.Int("log-file-max-size")) * 1024 * 1024
+		return logger.SetFile(path, maxSize, c.Int("log-file-max-backups"))
+	}
+
+	return nil
 }
@@ -0,0 +1,23 @@
+package gaper
+
+import (
+	"fmt"
+	"io/ioutil"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+// LoadConfigFile loads the settings from a YAML config file
+func LoadConfigFile(path string) (*Config, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't read config file: %v", err)
+	}
+
+	cfg := &Config{}
+	if err := yaml.UnmarshalStrict(data, cfg); err != nil {
+		return nil, fmt.Errorf("couldn't parse config file \"%s\": %v", path, err)
+	}
+
+	return cfg, nil
+}
@@ -0,0 +1,41 @@
+package gaper
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConfigLoadFile(t *testing.T) {
+	cfg, err := LoadConfigFile(filepath.Join("testdata", "services.yml"))
+	assert.Nil(t, err, "config error")
+	assert.Equal(t, 100, cfg.PollInterval)
+	assert.Equal(t, []string{"go"}, cfg.Extensions)
+	assert.Equal(t, []string{"./testdata/**/*_test.go"}, cfg.IgnoreItems)
+	assert.Equal(t, []ServiceConfig{
+		{
+			Name:              "api",
+			BuildPath:         "./testdata/server",
+			ProgramArgsMerged: "-port 8080",
+		},
+		{
+			Name:       "worker",
+			BuildPath:  "./testdata/modules/app",
+			BinName:    "worker-dev",
+			WatchItems: []string{"./testdata/modules/app"},
+		},
+	}, cfg.Services)
+}
+
+func TestConfigLoadFileUnknownField(t *testing.T) {
+	_, err := LoadConfigFile(filepath.Join("testdata", "invalid-config.yml"))
+	assert.NotNil(t, err, "config error")
+	assert.Contains(t, err.Error(), "field unknown_field not found")
+}
+
+func TestConfigLoadFileNotFound(t *testing.T) {
+	_, err := LoadConfigFile(filepath.Join("testdata", "not-found.yml"))
+	assert.NotNil(t, err, "config error")
+	assert.Contains(t, err.Error(), "couldn't read config file")
+}
@@ -0,0 +1,272 @@
+package gaper
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// controlUnixPrefix is used on the control address to listen on a unix socket
+const controlUnixPrefix = "unix:"
+
+// controlServer exposes an HTTP API to control the supervised programs
+type controlServer struct {
+	commands chan<- Command
+	history  *History
+	events   *eventBus
+	watcher  Watcher
+	server   *http.Server
+	listener net.Listener
+	// the host of the requests is not checked on a unix socket, which web pages can't reach
+	unix bool
+	done chan bool
+}
+
+// ServiceStatus is the state of a supervised program reported by the control API
+type ServiceStatus struct {
+	Name       string  `json:"name,omitempty"`
+	Running    bool    `json:"running"`
+	PID        int     `json:"pid,omitempty"`
+	Uptime     float64 `json:"uptime_seconds,omitempty"`
+	BuildError string  `json:"build_error,omitempty"`
+	Exited     bool    `json:"exited"`
+	ExitCode   int     `json:"exit_code"`
+	LastCycle  string  `json:"last_cycle,omitempty"`
+	// latest exit of the program, kept while it is restarted
+	LastExit *ExitStatus `json:"last_exit,omitempty"`
+}
+
+// ExitStatus is the exit of a supervised program reported by the control API
+type ExitStatus struct {
+	ExitCode int    `json:"exit_code"`
+	Summary  string `json:"summary"`
+}
+
+// Status is the state of gaper reported by the control API
+type Status struct {
+	Paused   bool            `json:"paused"`
+	Services []ServiceStatus `json:"services"`
+}
+
+// startControlServer starts the control API on the given address, which must be
+// on localhost (e.g. "localhost:7070" or ":7070") or a unix socket (e.g. "unix:/tmp/gaper.sock")
+func startControlServer(addr string, commands chan<- Command, history *History, events *eventBus,
+	watcher Watcher) (*controlServer, error) {
+	listener, err := listenControl(addr)
+	if err != nil {
+		return nil, fmt.Errorf("control server error: %v", err)
+	}
+
+	s := &controlServer{
+		commands: commands,
+		history:  history,
+		events:   events,
+		watcher:  watcher,
+		listener: listener,
+		unix:     strings.HasPrefix(addr, controlUnixPrefix),
+		done:     make(chan bool),
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/restart", s.handleCommand(CommandRestart))
+	mux.HandleFunc("/stop", s.handleCommand(CommandStop))
+	mux.HandleFunc("/start", s.handleCommand(CommandStart))
+	mux.HandleFunc("/pause", s.handleCommand(CommandPause))
+	mux.HandleFunc("/resume", s.handleCommand(CommandResume))
+	mux.HandleFunc("/status", s.handleStatus)
+	mux.HandleFunc("/events", s.handleEvents)
+	s.server = &http.Server{Handler: s.checkOrigin(mux)}
+
+	go func() {
+		if err := s.server.Serve(listener); err != nil && err != http.ErrServerClosed {
+			logger.Error("Error on control server:", err)
+		}
+	}()
+
+	logger.Info("Control server listening on " + addr)
+	return s, nil
+}
+
+// listenControl listens on a unix socket or on a localhost address
+func listenControl(addr string) (net.Listener, error) {
+	if strings.HasPrefix(addr, controlUnixPrefix) {
+		path := strings.TrimPrefix(addr, controlUnixPrefix)
+		// remove a socket left behind by a previous run
+		if info, err := os.Stat(path); err == nil && info.Mode()&os.ModeSocket != 0 {
+			os.Remove(path) // nolint errcheck
+		}
+		return net.Listen("unix", path)
+	}
+
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+
+	if host == "" {
+		host = "localhost"
+	}
+
+	if !isLoopback(host) {
+		return nil, fmt.Errorf("address \"%s\" must be on localhost or a unix socket", addr)
+	}
+
+	return net.Listen("tcp", net.JoinHostPort(host, port))
+}
+
+// isLoopback checks if the host is localhost or a loopback IP
+func isLoopback(host string) bool {
+	if host == "localhost" {
+		return true
+	}
+
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// checkOrigin rejects the requests which may come from web pages opened in a browser:
+// the host must be on localhost, so pages from other hosts resolving to localhost
+// (DNS rebinding) can't read the API, and the origin, when set, must be the API itself
+func (s *controlServer) checkOrigin(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !s.unix {
+			host, _, err := net.SplitHostPort(r.Host)
+			if err != nil {
+				host = strings.Trim(r.Host, "[]")
+			}
+
+			if !isLoopback(host) {
+				http.Error(w, "host not allowed", http.StatusForbidden)
+				return
+			}
+		}
+
+		if origin := r.Header.Get("Origin"); origin != "" {
+			u, err := url.Parse(origin)
+			if err != nil || u.Host != r.Host {
+				http.Error(w, "origin not allowed", http.StatusForbidden)
+				return
+			}
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Close stops the control server
+func (s *controlServer) Close() error {
+	close(s.done)
+	return s.server.Close()
+}
+
+// handleCommand sends a command to the run loop
+func (s *controlServer) handleCommand(cmd Command) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		select {
+		case s.commands <- cmd:
+			w.WriteHeader(http.StatusAccepted)
+		case <-r.Context().Done():
+		case <-s.done:
+			http.Error(w, "gaper is stopping", http.StatusServiceUnavailable)
+		}
+	}
+}
+
+// handleStatus reports the state of the supervised programs from their latest cycles
+// and exits, and whether the watching is paused by a command or a pause file
+func (s *controlServer) handleStatus(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	status := Status{Paused: s.watcher.Paused(), Services: []ServiceStatus{}}
+
+	latest := map[string]Cycle{}
+	var names []string
+	for _, c := range s.history.Cycles() {
+		if _, ok := latest[c.Service]; !ok {
+			names = append(names, c.Service)
+		}
+		latest[c.Service] = c
+	}
+
+	for _, name := range names {
+		c := latest[name]
+		svcStatus := ServiceStatus{
+			Name:      name,
+			Exited:    c.Exited,
+			ExitCode:  c.ExitCode,
+			LastCycle: c.Summary(),
+		}
+
+		if c.BuildError != nil {
+			svcStatus.BuildError = c.BuildError.Error()
+			svcStatus.LastCycle = ""
+		}
+
+		if exit, ok := s.history.LastExit(name); ok {
+			svcStatus.LastExit = &ExitStatus{ExitCode: exit.ExitCode, Summary: exit.Summary()}
+		}
+
+		if !c.ProgramStartedAt.IsZero() && !c.Exited {
+			svcStatus.Running = true
+			svcStatus.PID = c.PID
+			svcStatus.Uptime = time.Since(c.ProgramStartedAt).Seconds()
+		}
+
+		status.Services = append(status.Services, svcStatus)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(status); err != nil {
+		logger.Debug("Error writing status:", err)
+	}
+}
+
+// handleEvents streams the events as server-sent events until the client disconnects
+func (s *controlServer) handleEvents(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming not supported", http.StatusInternalServerError)
+		return
+	}
+
+	sub := s.events.subscribe()
+	defer s.events.unsubscribe(sub)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	for {
+		select {
+		case e := <-sub:
+			data, err := json.Marshal(e)
+			if err != nil {
+				logger.Debug("Error encoding event:", err)
+				continue
+			}
+
+			if _, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", e.Type, data); err != nil {
+				return
+			}
+			flusher.Flush()
+		case <-r.Context().Done():
+			return
+		case <-s.done:
+			return
+		}
+	}
+}
@@ -0,0 +1,179 @@
+package gaper
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestControlServer(t *testing.T) (*controlServer, chan Command, *History, Watcher, string) {
+	commands := make(chan Command, 1)
+	history := NewHistory(0)
+	watcher := &noopWatcher{}
+
+	s, err := startControlServer("localhost:0", commands, history, newEventBus(), watcher)
+	assert.Nil(t, err, "control server error")
+	return s, commands, history, watcher, "http://" + s.listener.Addr().String()
+}
+
+func TestControlServerCommands(t *testing.T) {
+	s, commands, _, _, url := newTestControlServer(t)
+	defer s.Close() // nolint errcheck
+
+	testCases := map[string]Command{
+		"/restart": CommandRestart,
+		"/stop":    CommandStop,
+		"/start":   CommandStart,
+		"/pause":   CommandPause,
+		"/resume":  CommandResume,
+	}
+
+	for path, cmd := range testCases {
+		t.Run(path, func(t *testing.T) {
+			resp, err := http.Post(url+path, "", nil)
+			assert.Nil(t, err, "request error")
+			resp.Body.Close() // nolint errcheck
+			assert.Equal(t, http.StatusAccepted, resp.StatusCode)
+			assert.Equal(t, cmd, <-commands)
+		})
+	}
+
+	resp, err := http.Get(url + "/restart")
+	assert.Nil(t, err, "request error")
+	resp.Body.Close() // nolint errcheck
+	assert.Equal(t, http.StatusMethodNotAllowed, resp.StatusCode)
+}
+
+func TestControlServerStatus(t *testing.T) {
+	s, _, history, watcher, url := newTestControlServer(t)
+	defer s.Close() // nolint errcheck
+
+	now := time.Now()
+	history.add(&Cycle{Service: "api", Trigger: CycleTriggerStart, PID: 42, ProgramStartedAt: now.Add(-time.Minute)})
+	history.add(&Cycle{Service: "worker", Trigger: CycleTriggerStart, PID: 43, ProgramStartedAt: now, Exited: true, ExitCode: 2})
+	history.add(&Cycle{Service: "api", Trigger: CycleTriggerChange, BuildError: errors.New("build-error")})
+	history.addExit(ExitInfo{Service: "worker", ExitCode: 2})
+	history.add(&Cycle{Service: "worker", Trigger: CycleTriggerExit, PID: 44, ProgramStartedAt: now})
+
+	// paused as it happens with a pause file, without a pause command
+	watcher.Pause()
+
+	resp, err := http.Get(url + "/status")
+	assert.Nil(t, err, "request error")
+	defer resp.Body.Close() // nolint errcheck
+
+	var status Status
+	assert.Nil(t, json.NewDecoder(resp.Body).Decode(&status), "decode error")
+	assert.True(t, status.Paused)
+	assert.Len(t, status.Services, 2)
+
+	api := status.Services[0]
+	assert.Equal(t, "api", api.Name)
+	assert.Equal(t, "build-error", api.BuildError)
+	assert.False(t, api.Running)
+	assert.Nil(t, api.LastExit)
+
+	worker := status.Services[1]
+	assert.Equal(t, "worker", worker.Name)
+	assert.True(t, worker.Running)
+	assert.Equal(t, 44, worker.PID)
+	assert.False(t, worker.Exited)
+	assert.Equal(t, &ExitStatus{ExitCode: 2, Summary: "exited with code 2"}, worker.LastExit)
+}
+
+func TestControlServerRejectsBrowsers(t *testing.T) {
+	s, commands, _, _, url := newTestControlServer(t)
+	defer s.Close() // nolint errcheck
+
+	// a page on another host resolving to localhost (DNS rebinding)
+	req, err := http.NewRequest(http.MethodGet, url+"/status", nil)
+	assert.Nil(t, err, "request error")
+	_, port, err := net.SplitHostPort(s.listener.Addr().String())
+	assert.Nil(t, err, "address error")
+	req.Host = "attacker.example.com:" + port
+	resp, err := http.DefaultClient.Do(req)
+	assert.Nil(t, err, "request error")
+	resp.Body.Close() // nolint errcheck
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+
+	// a page from another origin posting a command
+	req, err = http.NewRequest(http.MethodPost, url+"/restart", nil)
+	assert.Nil(t, err, "request error")
+	req.Header.Set("Origin", "http://attacker.example.com")
+	resp, err = http.DefaultClient.Do(req)
+	assert.Nil(t, err, "request error")
+	resp.Body.Close() // nolint errcheck
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+	assert.Len(t, commands, 0)
+
+	// the same origin is allowed
+	req, err = http.NewRequest(http.MethodPost, url+"/restart", nil)
+	assert.Nil(t, err, "request error")
+	req.Header.Set("Origin", url)
+	resp, err = http.DefaultClient.Do(req)
+	assert.Nil(t, err, "request error")
+	resp.Body.Close() // nolint errcheck
+	assert.Equal(t, http.StatusAccepted, resp.StatusCode)
+	assert.Equal(t, CommandRestart, <-commands)
+}
+
+func TestControlServerEvents(t *testing.T) {
+	commands := make(chan Command)
+	events := newEventBus()
+	s, err := startControlServer("localhost:0", commands, NewHistory(0), events, &noopWatcher{})
+	assert.Nil(t, err, "control server error")
+	defer s.Close() // nolint errcheck
+
+	resp, err := http.Get("http://" + s.listener.Addr().String() + "/events")
+	assert.Nil(t, err, "request error")
+	defer resp.Body.Close() // nolint errcheck
+	assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+
+	events.publish(Event{Type: EventStart, Service: "api", PID: 42})
+
+	reader := bufio.NewReader(resp.Body)
+	line, err := reader.ReadString('\n')
+	assert.Nil(t, err, "read error")
+	assert.Equal(t, "event: start\n", line)
+
+	line, err = reader.ReadString('\n')
+	assert.Nil(t, err, "read error")
+	assert.True(t, strings.HasPrefix(line, "data: "))
+
+	var e Event
+	assert.Nil(t, json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &e), "decode error")
+	assert.Equal(t, "api", e.Service)
+	assert.Equal(t, 42, e.PID)
+}
+
+func TestControlServerUnixSocket(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gaper-control")
+	assert.Nil(t, err, "temp dir error")
+	defer os.RemoveAll(dir) // nolint errcheck
+
+	path := filepath.Join(dir, "gaper.sock")
+	s, err := startControlServer(controlUnixPrefix+path, make(chan Command), NewHistory(0), newEventBus(), &noopWatcher{})
+	assert.Nil(t, err, "control server error")
+
+	info, err := os.Stat(path)
+	assert.Nil(t, err, "socket error")
+	assert.True(t, info.Mode()&os.ModeSocket != 0)
+	assert.Nil(t, s.Close())
+}
+
+func TestControlServerInvalidAddr(t *testing.T) {
+	for _, addr := range []string{"0.0.0.0:7070", "example.com:7070", "7070"} {
+		_, err := startControlServer(addr, make(chan Command), NewHistory(0), newEventBus(), &noopWatcher{})
+		assert.NotNil(t, err, "address error for "+addr)
+	}
+}
@@ -0,0 +1,103 @@
+package gaper
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// DefaultCoverDir is the directory where the coverage data and reports are written to
+var DefaultCoverDir = "coverage"
+
+// coverage files written on the coverage directory
+const (
+	coverRunsDir     = "runs"
+	coverProfileFile = "coverage.out"
+	coverHTMLFile    = "coverage.html"
+)
+
+// coverRunsPath returns the directory with the coverage data of every run,
+// which is empty when the coverage is not collected
+func coverRunsPath(cfg *Config) string {
+	if !cfg.Cover {
+		return ""
+	}
+
+	return filepath.Join(cfg.CoverDir, coverRunsDir)
+}
+
+// resetCoverage removes the coverage data from previous gaper executions
+func resetCoverage(coverDir string) error {
+	runsDir := filepath.Join(coverDir, coverRunsDir)
+	if err := os.RemoveAll(runsDir); err != nil {
+		return fmt.Errorf("coverage error: %v", err)
+	}
+
+	if err := os.MkdirAll(runsDir, 0755); err != nil {
+		return fmt.Errorf("coverage error: %v", err)
+	}
+
+	return nil
+}
+
+// newCoverRunDir creates the directory for the coverage data of a single run
+func newCoverRunDir(runsDir string) (string, error) {
+	if err := os.MkdirAll(runsDir, 0755); err != nil {
+		return "", err
+	}
+
+	return ioutil.TempDir(runsDir, "run-")
+}
+
+// mergeCoverage merges the coverage data from all runs into a profile and an HTML report,
+// the go commands run on dir so the packages of the profile are resolved from its module
+func mergeCoverage(coverDir string, dir string) error {
+	runsDir := filepath.Join(coverDir, coverRunsDir)
+	entries, err := ioutil.ReadDir(runsDir)
+	if err != nil {
+		return fmt.Errorf("coverage error: %v", err)
+	}
+
+	var runDirs []string
+	for _, entry := range entries {
+		files, err := ioutil.ReadDir(filepath.Join(runsDir, entry.Name()))
+		if err == nil && len(files) > 0 {
+			runDirs = append(runDirs, filepath.Join(runsDir, entry.Name()))
+		}
+	}
+
+	if len(runDirs) == 0 {
+		logger.Warn("No coverage data written by the programs, they must exit on their own or by handling SIGINT")
+		return nil
+	}
+
+	profile := filepath.Join(coverDir, coverProfileFile)
+	if err := runGoTool(dir, "covdata", "textfmt", "-i="+strings.Join(runDirs, ","), "-o="+profile); err != nil {
+		return err
+	}
+
+	html := filepath.Join(coverDir, coverHTMLFile)
+	if err := runGoTool(dir, "cover", "-html="+profile, "-o="+html); err != nil {
+		return err
+	}
+
+	logger.InfoWith("Coverage report written", Field("runs", len(runDirs)),
+		Field("profile", profile), Field("html", html))
+	return nil
+}
+
+// runGoTool runs a go tool command, returning its output on failure
+func runGoTool(dir string, args ...string) error {
+	command := exec.Command("go", append([]string{"tool"}, args...)...) // nolint gas
+	command.Dir = dir
+
+	output, err := command.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("coverage error: go tool %s failed with %v\n%s", args[0], err, output)
+	}
+
+	return nil
+}
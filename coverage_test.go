@@ -0,0 +1,78 @@
+package gaper
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCoverRunsPath(t *testing.T) {
+	assert.Equal(t, "", coverRunsPath(&Config{CoverDir: "coverage"}))
+	assert.Equal(t, filepath.Join("coverage", "runs"), coverRunsPath(&Config{Cover: true, CoverDir: "coverage"}))
+}
+
+func TestMergeCoverageNoData(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gaper-cover")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir) // nolint errcheck
+
+	assert.Nil(t, resetCoverage(dir), "reset error")
+	_, err = newCoverRunDir(filepath.Join(dir, coverRunsDir))
+	assert.Nil(t, err, "run directory error")
+
+	assert.Nil(t, mergeCoverage(dir, "."), "merge error")
+	_, err = os.Stat(filepath.Join(dir, coverProfileFile))
+	assert.True(t, os.IsNotExist(err), "no profile without coverage data")
+}
+
+func TestMergeCoverage(t *testing.T) {
+	if err := exec.Command("go", "tool", "-n", "covdata").Run(); err != nil {
+		t.Skip("go tool covdata is not available")
+	}
+
+	dir, err := ioutil.TempDir("", "gaper-cover")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir) // nolint errcheck
+
+	builder := NewBuilderWithConfig(BuilderConfig{
+		Dir:              filepath.Join("testdata", "cover"),
+		BinName:          "cover",
+		WorkingDirectory: dir,
+		Cover:            true,
+	})
+	assert.Nil(t, builder.Build(), "build error")
+	assert.Nil(t, resetCoverage(dir), "reset error")
+
+	// coverage from both runs is merged
+	for _, args := range [][]string{nil, {"-v"}} {
+		runner := NewRunnerWithConfig(RunnerConfig{
+			Stdout:   ioutil.Discard,
+			Stderr:   ioutil.Discard,
+			Bin:      filepath.Join(dir, builder.Binary()),
+			Args:     args,
+			CoverDir: filepath.Join(dir, coverRunsDir),
+		})
+
+		_, err := runner.Run()
+		assert.Nil(t, err, "error running binary")
+		assert.Nil(t, <-runner.Errors(), "async error running binary")
+	}
+
+	assert.Nil(t, mergeCoverage(dir, "."), "merge error")
+
+	profile, err := ioutil.ReadFile(filepath.Join(dir, coverProfileFile))
+	assert.Nil(t, err, "profile written")
+	assert.Contains(t, string(profile), "testdata/cover/main.go:10.3,12.1 2 1")
+	assert.Contains(t, string(profile), "testdata/cover/main.go:14.2,14.24 1 1")
+
+	_, err = os.Stat(filepath.Join(dir, coverHTMLFile))
+	assert.Nil(t, err, "html report written")
+}
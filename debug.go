@@ -0,0 +1,54 @@
+package gaper
+
+import (
+	"fmt"
+	"net"
+	"os/exec"
+	"strconv"
+)
+
+// DefaultDebugAddr is the address the debugger listens on in debug mode
+var DefaultDebugAddr = ":2345"
+
+// debugBuildArgs disables the optimizations and inlining, so the debugger can follow the code
+var debugBuildArgs = []string{"-gcflags=all=-N -l"}
+
+// debuggerBin is the debugger the programs run under in debug mode
+var debuggerBin = "dlv"
+
+// debugCommand returns the command running the binary under a headless debugger
+// listening on the address, which accepts multiple clients so an editor can attach
+// to it while another client (e.g. a terminal) is also attached
+func debugCommand(addr string, bin string, args []string) (string, []string) {
+	debugArgs := []string{"exec", "--headless", "--listen=" + addr, "--accept-multiclient", bin}
+	if len(args) > 0 {
+		debugArgs = append(append(debugArgs, "--"), args...)
+	}
+
+	return debuggerBin, debugArgs
+}
+
+// checkDebugger checks the debugger is installed
+func checkDebugger() error {
+	if _, err := exec.LookPath(debuggerBin); err != nil {
+		return fmt.Errorf("debug mode requires delve (go install github.com/go-delve/delve/cmd/dlv@latest): %v", err)
+	}
+
+	return nil
+}
+
+// serviceDebugAddr returns the address the debugger of a service listens on, which is
+// the main debug address with its port increased by the service position
+func serviceDebugAddr(addr string, index int) (string, error) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return "", fmt.Errorf("invalid debug address \"%s\": %v", addr, err)
+	}
+
+	number, err := strconv.Atoi(port)
+	if err != nil {
+		return "", fmt.Errorf("invalid debug address \"%s\": port must be a number", addr)
+	}
+
+	return net.JoinHostPort(host, strconv.Itoa(number+index)), nil
+}
@@ -0,0 +1,52 @@
+package gaper
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDebugCommand(t *testing.T) {
+	bin, args := debugCommand(":2345", "/src/srv", []string{"-port", "8080"})
+	assert.Equal(t, "dlv", bin)
+	assert.Equal(t, []string{"exec", "--headless", "--listen=:2345", "--accept-multiclient",
+		"/src/srv", "--", "-port", "8080"}, args)
+
+	_, args = debugCommand("localhost:40000", "/src/srv", nil)
+	assert.Equal(t, []string{"exec", "--headless", "--listen=localhost:40000", "--accept-multiclient", "/src/srv"}, args)
+}
+
+func TestServiceDebugAddr(t *testing.T) {
+	addr, err := serviceDebugAddr(":2345", 2)
+	assert.Nil(t, err, "valid address")
+	assert.Equal(t, ":2347", addr)
+
+	addr, err = serviceDebugAddr("localhost:40000", 0)
+	assert.Nil(t, err, "valid address")
+	assert.Equal(t, "localhost:40000", addr)
+
+	_, err = serviceDebugAddr("2345", 0)
+	assert.NotNil(t, err, "missing port")
+
+	_, err = serviceDebugAddr(":dlv", 0)
+	assert.NotNil(t, err, "invalid port")
+	assert.Equal(t, "invalid debug address \":dlv\": port must be a number", err.Error())
+}
+
+func TestServiceSetupDebugAddr(t *testing.T) {
+	cfg := &Config{
+		Debug:     true,
+		DebugAddr: DefaultDebugAddr,
+		Services: []ServiceConfig{
+			{Name: "api"},
+			{Name: "worker", DebugAddr: ":40000"},
+			{Name: "cron"},
+		},
+	}
+
+	err := setupServices(cfg)
+	assert.Nil(t, err, "setup error")
+	assert.Equal(t, ":2345", cfg.Services[0].DebugAddr)
+	assert.Equal(t, ":40000", cfg.Services[1].DebugAddr)
+	assert.Equal(t, ":2347", cfg.Services[2].DebugAddr)
+}
@@ -0,0 +1,295 @@
+package gaper
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/fatih/color"
+)
+
+// Diagnostics file formats
+var (
+	DiagnosticsFormatQuickfix = "quickfix"
+	DiagnosticsFormatJSON     = "json"
+)
+
+// diagnosticPattern matches the compiler errors (e.g. "./main.go:4:6: missing return"),
+// the column is optional as some errors only have the line
+var diagnosticPattern = regexp.MustCompile(`^(.+\.go):(\d+)(?::(\d+))?: (.+)$`)
+
+// Diagnostic is an error reported by the compiler on building a program
+type Diagnostic struct {
+	// name of the service, empty when there are no services
+	Service string `json:"service,omitempty"`
+	// package being built, as reported by the compiler
+	Package string `json:"package,omitempty"`
+	// absolute path of the file
+	File   string `json:"file"`
+	Line   int    `json:"line"`
+	Column int    `json:"column,omitempty"`
+	// message from the compiler, the lines after the first one
+	// give more details (e.g. "have (int)" and "want ()")
+	Message string `json:"message"`
+}
+
+// location returns the position of the diagnostic on the file (e.g. "main.go:4:6")
+func (d Diagnostic) location(file string) string {
+	if d.Column == 0 {
+		return fmt.Sprintf("%s:%d", file, d.Line)
+	}
+
+	return fmt.Sprintf("%s:%d:%d", file, d.Line, d.Column)
+}
+
+// BuildError is returned when a program fails to build, with the
+// compiler errors parsed from the build output
+type BuildError struct {
+	Err         error
+	Output      []byte
+	Diagnostics []Diagnostic
+}
+
+// Error describes the build failure along with the whole build output
+func (e *BuildError) Error() string {
+	return fmt.Sprintf("build failed with %v\n%s", e.Err, e.Output)
+}
+
+// brief describes the build failure without the build output, which is
+// used once the diagnostics have been reported on their own
+func (e *BuildError) brief() string {
+	if len(e.Diagnostics) == 1 {
+		return fmt.Sprintf("build failed with %v (1 error)", e.Err)
+	}
+
+	return fmt.Sprintf("build failed with %v (%d errors)", e.Err, len(e.Diagnostics))
+}
+
+// newBuildError creates the error of a failed build, the relative paths on the
+// output are resolved from the directory the build command ran on
+func newBuildError(service string, err error, output []byte, dir string) *BuildError {
+	return &BuildError{Err: err, Output: output, Diagnostics: parseDiagnostics(service, output, dir)}
+}
+
+// parseDiagnostics parses the compiler errors from the build output
+func parseDiagnostics(service string, output []byte, dir string) []Diagnostic {
+	absDir, err := filepath.Abs(dir)
+	if err != nil {
+		absDir = dir
+	}
+
+	var diagnostics []Diagnostic
+	pkg := ""
+	for _, line := range strings.Split(string(output), "\n") {
+		line = strings.TrimRight(line, "\r")
+
+		// details of the previous error are indented
+		if strings.HasPrefix(line, "\t") && len(diagnostics) > 0 {
+			diagnostics[len(diagnostics)-1].Message += "\n" + line
+			continue
+		}
+
+		if strings.HasPrefix(line, "# ") {
+			pkg = strings.TrimPrefix(line, "# ")
+			continue
+		}
+
+		match := diagnosticPattern.FindStringSubmatch(line)
+		if match == nil {
+			continue
+		}
+
+		file := match[1]
+		if !filepath.IsAbs(file) {
+			file = filepath.Join(absDir, file)
+		}
+
+		// the pattern guarantees the numbers
+		lineNumber, _ := strconv.Atoi(match[2]) // nolint errcheck
+		column, _ := strconv.Atoi(match[3])     // nolint errcheck
+
+		diagnostics = append(diagnostics, Diagnostic{
+			Service: service,
+			Package: pkg,
+			File:    file,
+			Line:    lineNumber,
+			Column:  column,
+			Message: match[4],
+		})
+	}
+
+	return diagnostics
+}
+
+// diagnosticsReporter reports the diagnostics of the failed builds and keeps the
+// diagnostics file updated with the ones from the last build of every program
+type diagnosticsReporter struct {
+	mu sync.Mutex
+	// the paths are shown relative to this directory
+	wd  string
+	out io.Writer
+	// file the diagnostics are written to, empty to not write them
+	file   string
+	format string
+	// diagnostics from the last build of each service
+	byService map[string][]Diagnostic
+}
+
+// newDiagnosticsReporter creates the reporter for the settings
+func newDiagnosticsReporter(cfg *Config) *diagnosticsReporter {
+	return &diagnosticsReporter{
+		wd:        cfg.WorkingDirectory,
+		out:       os.Stderr,
+		file:      cfg.DiagnosticsFile,
+		format:    cfg.DiagnosticsFormat,
+		byService: map[string][]Diagnostic{},
+	}
+}
+
+// report shows the diagnostics from the build of a service and updates the diagnostics
+// file, a successful build clears the diagnostics of the service
+func (r *diagnosticsReporter) report(service string, err error) {
+	if r == nil {
+		return
+	}
+
+	var diagnostics []Diagnostic
+	if buildErr, ok := err.(*BuildError); ok {
+		diagnostics = buildErr.Diagnostics
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if len(diagnostics) > 0 {
+		r.show(diagnostics)
+	}
+
+	r.byService[service] = diagnostics
+	if r.file != "" {
+		if err := r.write(); err != nil {
+			logger.Error("Error writing diagnostics file:", err)
+		}
+	}
+}
+
+// show writes the diagnostics with colors and links to the files, or as log
+// messages with the diagnostic fields when the logs are in JSON
+func (r *diagnosticsReporter) show(diagnostics []Diagnostic) {
+	if logger.format == LogFormatJSON {
+		for _, d := range diagnostics {
+			fields := []LogField{Field("package", d.Package), Field("file", relativePath(r.wd, d.File)),
+				Field("line", d.Line), Field("column", d.Column)}
+			if d.Service != "" {
+				fields = append(fields, Field("service", d.Service))
+			}
+			logger.ErrorWith(d.Message, fields...)
+		}
+		return
+	}
+
+	pkg := ""
+	var b bytes.Buffer
+	for _, d := range diagnostics {
+		if d.Package != "" && d.Package != pkg {
+			pkg = d.Package
+			b.WriteString(color.YellowString("# "+pkg) + "\n")
+		}
+
+		location := color.New(color.Bold).Sprint(d.location(relativePath(r.wd, d.File)))
+		fmt.Fprintf(&b, "%s: %s\n", hyperlink(fileURL(d.File), location), color.RedString(d.Message))
+	}
+
+	r.out.Write(b.Bytes()) // nolint errcheck
+}
+
+// write writes the diagnostics of all services to the diagnostics file
+func (r *diagnosticsReporter) write() error {
+	services := make([]string, 0, len(r.byService))
+	for service := range r.byService {
+		services = append(services, service)
+	}
+	sort.Strings(services)
+
+	all := []Diagnostic{}
+	for _, service := range services {
+		all = append(all, r.byService[service]...)
+	}
+
+	var data []byte
+	if r.format == DiagnosticsFormatJSON {
+		var err error
+		if data, err = json.MarshalIndent(all, "", "  "); err != nil {
+			return err
+		}
+		data = append(data, '\n')
+	} else {
+		// one line per diagnostic (e.g. "/src/main.go:4:6: missing return"),
+		// as read by the errorformat of vim and the problem matchers of editors
+		var b bytes.Buffer
+		for _, d := range all {
+			fmt.Fprintf(&b, "%s: %s\n", d.location(d.File), strings.Replace(d.Message, "\n\t", " ", -1))
+		}
+		data = b.Bytes()
+	}
+
+	return ioutil.WriteFile(r.file, data, 0644)
+}
+
+// fileURL returns the URL of an absolute path (e.g. "file:///src/main.go")
+func fileURL(path string) string {
+	path = filepath.ToSlash(path)
+	if !strings.HasPrefix(path, "/") {
+		// Windows paths start with the drive letter
+		path = "/" + path
+	}
+
+	return "file://" + path
+}
+
+// hyperlink makes the text a link on the terminals supporting OSC 8,
+// which is only used when writing colors to a terminal
+func hyperlink(url string, text string) string {
+	if color.NoColor {
+		return text
+	}
+
+	return "\x1b]8;;" + url + "\x1b\\" + text + "\x1b]8;;\x1b\\"
+}
+
+// resolveDiagnosticsFormat returns the format of the diagnostics file,
+// which by default is JSON for the files with the ".json" extension
+func resolveDiagnosticsFormat(format string, file string) (string, error) {
+	switch format {
+	case "":
+		if strings.EqualFold(filepath.Ext(file), ".json") {
+			return DiagnosticsFormatJSON, nil
+		}
+		return DiagnosticsFormatQuickfix, nil
+	case DiagnosticsFormatQuickfix, DiagnosticsFormatJSON:
+		return format, nil
+	}
+
+	return "", fmt.Errorf("invalid diagnostics format \"%s\", supported formats: %s, %s",
+		format, DiagnosticsFormatQuickfix, DiagnosticsFormatJSON)
+}
+
+// buildErrorMessage returns the message logged for a build error,
+// which omits the build output once its diagnostics have been reported
+func buildErrorMessage(err error) error {
+	if buildErr, ok := err.(*BuildError); ok && len(buildErr.Diagnostics) > 0 {
+		return errors.New(buildErr.brief())
+	}
+
+	return err
+}
@@ -0,0 +1,160 @@
+package gaper
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/fatih/color"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseDiagnostics(t *testing.T) {
+	output := "# github.com/user/project/api\n" +
+		"./main.go:4:6: func main must have no arguments and no return values\n" +
+		"handlers/user.go:12:2: too many return values\n" +
+		"\thave (number)\n" +
+		"\twant ()\n" +
+		"# github.com/user/project/api/store\n" +
+		"/src/store/db.go:7: undefined: sql\n" +
+		"too many errors\n"
+
+	dir := filepath.Join("/src", "api")
+	diagnostics := parseDiagnostics("api", []byte(output), dir)
+	assert.Equal(t, []Diagnostic{
+		{
+			Service: "api",
+			Package: "github.com/user/project/api",
+			File:    filepath.Join(dir, "main.go"),
+			Line:    4,
+			Column:  6,
+			Message: "func main must have no arguments and no return values",
+		},
+		{
+			Service: "api",
+			Package: "github.com/user/project/api",
+			File:    filepath.Join(dir, "handlers", "user.go"),
+			Line:    12,
+			Column:  2,
+			Message: "too many return values\n\thave (number)\n\twant ()",
+		},
+		{
+			Service: "api",
+			Package: "github.com/user/project/api/store",
+			File:    "/src/store/db.go",
+			Line:    7,
+			Message: "undefined: sql",
+		},
+	}, diagnostics)
+}
+
+func TestBuildErrorMessage(t *testing.T) {
+	err := errors.New("exit status 1")
+	buildErr := &BuildError{Err: err, Output: []byte("./main.go:5:1: missing return\n"),
+		Diagnostics: []Diagnostic{{File: "/src/main.go", Line: 5, Column: 1, Message: "missing return"}}}
+
+	assert.Equal(t, "build failed with exit status 1\n./main.go:5:1: missing return\n", buildErr.Error())
+	assert.Equal(t, "build failed with exit status 1 (1 error)", buildErrorMessage(buildErr).Error())
+
+	// the whole output is kept when there is no diagnostic
+	buildErr = &BuildError{Err: err, Output: []byte("make: *** [build] Error 1\n")}
+	assert.Equal(t, buildErr, buildErrorMessage(buildErr))
+}
+
+func TestDiagnosticsReporterShow(t *testing.T) {
+	noColor := color.NoColor
+	color.NoColor = true
+	defer func() { color.NoColor = noColor }()
+
+	out := bytes.NewBufferString("")
+	reporter := &diagnosticsReporter{wd: "/src", out: out, byService: map[string][]Diagnostic{}}
+	reporter.report("", &BuildError{Err: errors.New("exit status 1"), Diagnostics: []Diagnostic{
+		{Package: "github.com/user/project", File: "/src/main.go", Line: 4, Column: 6, Message: "missing return"},
+		{Package: "github.com/user/project", File: "/src/db.go", Line: 7, Message: "undefined: sql"},
+	}})
+
+	assert.Equal(t, "# github.com/user/project\n"+
+		"main.go:4:6: missing return\n"+
+		"db.go:7: undefined: sql\n", out.String())
+}
+
+func TestDiagnosticsReporterWrite(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gaper-diagnostics")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir) // nolint errcheck
+
+	apiErr := &BuildError{Err: errors.New("exit status 1"), Diagnostics: []Diagnostic{
+		{Service: "api", File: "/src/api/main.go", Line: 12, Column: 2, Message: "too many return values\n\thave (number)"},
+	}}
+	workerErr := &BuildError{Err: errors.New("exit status 1"), Diagnostics: []Diagnostic{
+		{Service: "worker", File: "/src/worker/main.go", Line: 7, Message: "undefined: sql"},
+	}}
+
+	quickfix := filepath.Join(dir, "errors.txt")
+	reporter := &diagnosticsReporter{out: ioutil.Discard, file: quickfix, format: DiagnosticsFormatQuickfix,
+		byService: map[string][]Diagnostic{}}
+	reporter.report("worker", workerErr)
+	reporter.report("api", apiErr)
+
+	data, err := ioutil.ReadFile(quickfix)
+	assert.Nil(t, err, "quickfix file written")
+	assert.Equal(t, "/src/api/main.go:12:2: too many return values have (number)\n"+
+		"/src/worker/main.go:7: undefined: sql\n", string(data))
+
+	// a successful build clears the diagnostics of its service
+	reporter.report("api", nil)
+	data, err = ioutil.ReadFile(quickfix)
+	assert.Nil(t, err, "quickfix file written")
+	assert.Equal(t, "/src/worker/main.go:7: undefined: sql\n", string(data))
+
+	jsonFile := filepath.Join(dir, "errors.json")
+	reporter = &diagnosticsReporter{out: ioutil.Discard, file: jsonFile, format: DiagnosticsFormatJSON,
+		byService: map[string][]Diagnostic{}}
+	reporter.report("worker", workerErr)
+
+	data, err = ioutil.ReadFile(jsonFile)
+	assert.Nil(t, err, "json file written")
+	var diagnostics []Diagnostic
+	assert.Nil(t, json.Unmarshal(data, &diagnostics), "valid json")
+	assert.Equal(t, workerErr.Diagnostics, diagnostics)
+
+	reporter.report("worker", nil)
+	data, err = ioutil.ReadFile(jsonFile)
+	assert.Nil(t, err, "json file written")
+	assert.Equal(t, "[]\n", string(data))
+}
+
+func TestResolveDiagnosticsFormat(t *testing.T) {
+	format, err := resolveDiagnosticsFormat("", "errors.JSON")
+	assert.Nil(t, err, "valid format")
+	assert.Equal(t, DiagnosticsFormatJSON, format)
+
+	format, err = resolveDiagnosticsFormat("", "errors.txt")
+	assert.Nil(t, err, "valid format")
+	assert.Equal(t, DiagnosticsFormatQuickfix, format)
+
+	format, err = resolveDiagnosticsFormat(DiagnosticsFormatQuickfix, "errors.json")
+	assert.Nil(t, err, "valid format")
+	assert.Equal(t, DiagnosticsFormatQuickfix, format)
+
+	_, err = resolveDiagnosticsFormat("xml", "errors.xml")
+	assert.NotNil(t, err, "invalid format")
+	assert.Equal(t, "invalid diagnostics format \"xml\", supported formats: quickfix, json", err.Error())
+}
+
+func TestHyperlink(t *testing.T) {
+	noColor := color.NoColor
+	defer func() { color.NoColor = noColor }()
+
+	color.NoColor = false
+	assert.Equal(t, "\x1b]8;;file:///src/main.go\x1b\\main.go\x1b]8;;\x1b\\", hyperlink(fileURL("/src/main.go"), "main.go"))
+
+	color.NoColor = true
+	assert.Equal(t, "main.go", hyperlink(fileURL("/src/main.go"), "main.go"))
+}
@@ -0,0 +1,106 @@
+package gaper
+
+import (
+	"sync"
+	"time"
+)
+
+// EventType identifies what an event is about
+type EventType string
+
+// types of the events published while the programs are supervised
+const (
+	// watched files changed
+	EventChange EventType = "change"
+	// a program build started
+	EventBuildStart EventType = "build_start"
+	// a program has been built
+	EventBuildSuccess EventType = "build_success"
+	// a program build failed
+	EventBuildFailed EventType = "build_failed"
+	// a program process started
+	EventStart EventType = "start"
+	// a program process exited
+	EventExit EventType = "exit"
+	// the watching has been paused
+	EventPause EventType = "pause"
+	// the watching has been resumed
+	EventResume EventType = "resume"
+)
+
+// eventsBufferSize is the number of events buffered for each subscriber,
+// events are dropped for subscribers not keeping up with them
+var eventsBufferSize = 64
+
+// Event is something that happened to the supervised programs
+type Event struct {
+	Type    EventType `json:"type"`
+	Service string    `json:"service,omitempty"`
+	Time    time.Time `json:"time"`
+	// changed files for change events
+	Files []string `json:"files,omitempty"`
+	// pid for start events
+	PID int `json:"pid,omitempty"`
+	// exit code for exit events
+	ExitCode int `json:"exit_code"`
+	// build error for build failed events
+	Error string `json:"error,omitempty"`
+	// compiler errors parsed from the build error for build failed events
+	Diagnostics []Diagnostic `json:"diagnostics,omitempty"`
+	// build duration for build success and failed events
+	Duration time.Duration `json:"duration,omitempty"`
+	Summary  string        `json:"summary,omitempty"`
+}
+
+// eventBus delivers the published events to its subscribers
+type eventBus struct {
+	mu          sync.Mutex
+	subscribers map[chan Event]bool
+}
+
+func newEventBus() *eventBus {
+	return &eventBus{subscribers: map[chan Event]bool{}}
+}
+
+// subscribe creates a channel receiving the events published from now on
+func (b *eventBus) subscribe() chan Event {
+	ch := make(chan Event, eventsBufferSize)
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.subscribers[ch] = true
+	return ch
+}
+
+// unsubscribe stops delivering events to the channel and closes it
+func (b *eventBus) unsubscribe(ch chan Event) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.subscribers[ch] {
+		delete(b.subscribers, ch)
+		close(ch)
+	}
+}
+
+// publish sends the event to all subscribers without blocking
+func (b *eventBus) publish(e Event) {
+	if b == nil {
+		return
+	}
+
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for ch := range b.subscribers {
+		select {
+		case ch <- e:
+		default:
+			logger.Debug("Dropped event for slow subscriber:", e.Type)
+		}
+	}
+}
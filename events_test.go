@@ -0,0 +1,47 @@
+package gaper
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEventBusPublish(t *testing.T) {
+	bus := newEventBus()
+	first := bus.subscribe()
+	second := bus.subscribe()
+
+	bus.publish(Event{Type: EventStart, Service: "api", PID: 42})
+
+	for _, sub := range []chan Event{first, second} {
+		e := <-sub
+		assert.Equal(t, EventStart, e.Type)
+		assert.Equal(t, "api", e.Service)
+		assert.Equal(t, 42, e.PID)
+		assert.False(t, e.Time.IsZero())
+	}
+
+	bus.unsubscribe(first)
+	_, ok := <-first
+	assert.False(t, ok, "closed subscription")
+
+	bus.publish(Event{Type: EventExit})
+	assert.Equal(t, EventExit, (<-second).Type)
+}
+
+func TestEventBusSlowSubscriber(t *testing.T) {
+	bus := newEventBus()
+	sub := bus.subscribe()
+
+	// publishing doesn't block once the subscriber buffer is full
+	for i := 0; i < eventsBufferSize+10; i++ {
+		bus.publish(Event{Type: EventChange})
+	}
+
+	assert.Len(t, sub, eventsBufferSize)
+}
+
+func TestEventBusNil(t *testing.T) {
+	var bus *eventBus
+	bus.publish(Event{Type: EventChange})
+}
@@ -0,0 +1,286 @@
+package gaper
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// exit code given by shells to a process killed by SIGKILL
+const exitCodeKilled = 128 + 9
+
+// files with the count of processes killed for running out of memory
+// on the cgroup gaper runs in, for cgroup v2 and v1 respectively
+var oomKillFiles = []string{
+	"/sys/fs/cgroup/memory.events",
+	"/sys/fs/cgroup/memory/memory.oom_control",
+}
+
+// prefixes of the lines written to stderr by the Go runtime on a crash
+var panicPrefixes = []string{"panic: ", "fatal error: "}
+
+// ExitInfo describes the exit of a supervised program
+type ExitInfo struct {
+	// name of the service, empty when there are no services
+	Service string
+	// exit code of the program, -1 when it was killed by a signal
+	ExitCode int
+	// error returned on waiting for the program, nil if it exited with 0
+	Err error
+	// signal which killed the program, 0 if it was not killed by a signal
+	Signal   syscall.Signal
+	CoreDump bool
+	// the program was killed for running out of memory, detected by the cgroup
+	// oom_kill counter, always false when the counter is unavailable
+	OOMKilled bool
+	// first line of a Go panic or fatal error written to stderr
+	Panic string
+	// data races reported by the race detector while the program was running
+	Races int
+	// time the program was running
+	Runtime time.Duration
+	// consecutive restarts caused by exits, it is reset once
+	// the program is restarted by a file change or a command
+	Restarts int
+}
+
+// Success checks if the program exited with a 0 exit code
+func (e ExitInfo) Success() bool {
+	return e.ExitCode == 0
+}
+
+// Summary describes the exit in a single line
+// (e.g. "killed by signal 9 (killed) after 2m3s, likely out of memory")
+func (e ExitInfo) Summary() string {
+	var b strings.Builder
+
+	if e.Signal != 0 {
+		fmt.Fprintf(&b, "killed by signal %d (%v)", int(e.Signal), e.Signal)
+	} else {
+		fmt.Fprintf(&b, "exited with code %d", e.ExitCode)
+	}
+
+	if e.Runtime > 0 {
+		fmt.Fprintf(&b, " after %v", roundDuration(e.Runtime))
+	}
+
+	if e.CoreDump {
+		b.WriteString(" (core dumped)")
+	}
+
+	if e.OOMKilled {
+		b.WriteString(", likely out of memory")
+	}
+
+	if e.Panic != "" {
+		b.WriteString(", " + e.Panic)
+	}
+
+	if e.Races == 1 {
+		b.WriteString(", 1 data race")
+	} else if e.Races > 1 {
+		fmt.Fprintf(&b, ", %d data races", e.Races)
+	}
+
+	return b.String()
+}
+
+// shellExitCode returns the exit code following the shell convention
+// of 128 plus the signal number for the deaths by signal
+func (e ExitInfo) shellExitCode() int {
+	if e.Signal != 0 {
+		return 128 + int(e.Signal)
+	}
+
+	if e.ExitCode <= 0 {
+		return exitStatusError
+	}
+
+	return e.ExitCode
+}
+
+// fields returns the exit information as structured log fields
+func (e ExitInfo) fields() []LogField {
+	fields := []LogField{Field("exit_code", e.ExitCode), Field("runtime", e.Runtime.Round(time.Millisecond))}
+	if e.Signal != 0 {
+		fields = append(fields, Field("signal", e.Signal.String()))
+	}
+	if e.CoreDump {
+		fields = append(fields, Field("core_dump", true))
+	}
+	if e.OOMKilled {
+		fields = append(fields, Field("oom_killed", true))
+	}
+	if e.Panic != "" {
+		fields = append(fields, Field("panic", e.Panic))
+	}
+	if e.Races > 0 {
+		fields = append(fields, Field("races", e.Races))
+	}
+
+	return fields
+}
+
+// ProgramExitError is returned when gaper quits because a program ended
+// with a non zero exit code, so gaper can exit with the same code
+type ProgramExitError struct {
+	Exit ExitInfo
+}
+
+// Error describes the program exit
+func (e *ProgramExitError) Error() string {
+	return programName(e.Exit.Service) + " " + e.Exit.Summary()
+}
+
+// ExitCode returns the exit code gaper exits with
+func (e *ProgramExitError) ExitCode() int {
+	return e.Exit.shellExitCode()
+}
+
+// programExitError returns the error for quitting after a program exit,
+// which is nil if the program exited successfully
+func programExitError(exit ExitInfo) error {
+	if exit.Success() && exit.Signal == 0 {
+		return nil
+	}
+
+	return &ProgramExitError{Exit: exit}
+}
+
+// exitStatus resolves the exit status from the wait error
+func exitStatus(err error) int {
+	var exitStatus int
+	if exiterr, ok := err.(*exec.ExitError); ok {
+		if status, oks := exiterr.Sys().(syscall.WaitStatus); oks {
+			exitStatus = status.ExitStatus()
+		}
+	}
+
+	return exitStatus
+}
+
+// exitSignal resolves the signal which killed the program from the wait error
+func exitSignal(err error) (syscall.Signal, bool, bool) {
+	exitErr, ok := err.(*exec.ExitError)
+	if !ok {
+		return 0, false, false
+	}
+
+	status, ok := exitErr.Sys().(syscall.WaitStatus)
+	if !ok || !status.Signaled() {
+		return 0, false, false
+	}
+
+	return status.Signal(), true, status.CoreDump()
+}
+
+// exitInspector is implemented by the runners able to add details about
+// the exit of their programs that can't be resolved from the wait error
+type exitInspector interface {
+	inspectExit(err error, exit *ExitInfo)
+}
+
+// exitDetails are the details about an exit collected while the program runs
+type exitDetails struct {
+	panic     string
+	oomKilled bool
+	races     int
+}
+
+// panicDetector looks for a Go panic or fatal error in the program stderr
+type panicDetector struct {
+	mu    sync.Mutex
+	line  []byte
+	found string
+}
+
+// Write checks each line written for the Go runtime crash messages
+func (d *panicDetector) Write(data []byte) (int, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.found != "" {
+		return len(data), nil
+	}
+
+	d.line = append(d.line, data...)
+	for {
+		i := bytes.IndexByte(d.line, '\n')
+		if i < 0 {
+			break
+		}
+
+		line := strings.TrimSpace(string(d.line[:i]))
+		d.line = d.line[i+1:]
+		for _, prefix := range panicPrefixes {
+			if strings.HasPrefix(line, prefix) {
+				d.found = line
+				d.line = nil
+				return len(data), nil
+			}
+		}
+	}
+
+	// the beginning of very long lines is not kept
+	if len(d.line) > maxLineLength {
+		d.line = nil
+	}
+
+	return len(data), nil
+}
+
+// panicLine returns the first line of the panic found, if any
+func (d *panicDetector) panicLine() string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.found
+}
+
+// readOOMKills reads the count of processes killed for running out of memory,
+// returning false if the count is not available
+func readOOMKills() (int, bool) {
+	for _, path := range oomKillFiles {
+		f, err := os.Open(path)
+		if err != nil {
+			continue
+		}
+
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			fields := strings.Fields(scanner.Text())
+			if len(fields) == 2 && fields[0] == "oom_kill" {
+				count, err := strconv.Atoi(fields[1])
+				f.Close() // nolint errcheck
+				return count, err == nil
+			}
+		}
+
+		f.Close() // nolint errcheck
+	}
+
+	return 0, false
+}
+
+// killedByOOM checks if an exit was caused by running out of memory, comparing the
+// cgroup oom_kill count from before the program started. Without the count it is not
+// known, since a SIGKILL is also sent by other means (e.g. kill -9 or a hard kill)
+func killedByOOM(err error, exitCode int, oomKillsBefore int, oomKillsAvailable bool) bool {
+	if !oomKillsAvailable {
+		return false
+	}
+
+	sig, signaled, _ := exitSignal(err)
+	if !(signaled && sig == syscall.SIGKILL) && exitCode != exitCodeKilled {
+		return false
+	}
+
+	oomKills, ok := readOOMKills()
+	return ok && oomKills > oomKillsBefore
+}
@@ -0,0 +1,168 @@
+package gaper
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExitInfoSummary(t *testing.T) {
+	testCases := []struct {
+		name     string
+		exit     ExitInfo
+		expected string
+	}{
+		{
+			name:     "exit code",
+			exit:     ExitInfo{ExitCode: 1},
+			expected: "exited with code 1",
+		},
+		{
+			name:     "exit code with runtime",
+			exit:     ExitInfo{ExitCode: 0, Runtime: 1234 * time.Millisecond},
+			expected: "exited with code 0 after 1.2s",
+		},
+		{
+			name:     "signal",
+			exit:     ExitInfo{ExitCode: -1, Signal: syscall.SIGKILL, Runtime: 123 * time.Second, OOMKilled: true},
+			expected: "killed by signal 9 (killed) after 2m3s, likely out of memory",
+		},
+		{
+			name:     "core dump",
+			exit:     ExitInfo{ExitCode: -1, Signal: syscall.SIGABRT, CoreDump: true},
+			expected: "killed by signal 6 (aborted) (core dumped)",
+		},
+		{
+			name:     "panic",
+			exit:     ExitInfo{ExitCode: 2, Panic: "panic: boom"},
+			expected: "exited with code 2, panic: boom",
+		},
+		{
+			name:     "data races",
+			exit:     ExitInfo{ExitCode: 66, Races: 2},
+			expected: "exited with code 66, 2 data races",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, tc.exit.Summary())
+		})
+	}
+}
+
+func TestProgramExitError(t *testing.T) {
+	assert.Nil(t, programExitError(ExitInfo{ExitCode: 0}))
+
+	err := programExitError(ExitInfo{Service: "api", ExitCode: 4})
+	assert.Equal(t, "program api exited with code 4", err.Error())
+	assert.Equal(t, 4, err.(*ProgramExitError).ExitCode())
+
+	err = programExitError(ExitInfo{ExitCode: -1, Signal: syscall.SIGKILL})
+	assert.Equal(t, 137, err.(*ProgramExitError).ExitCode())
+
+	// killed by a signal without an exit code
+	err = programExitError(ExitInfo{ExitCode: 0, Signal: syscall.SIGTERM})
+	assert.Equal(t, 143, err.(*ProgramExitError).ExitCode())
+}
+
+func TestExitSignal(t *testing.T) {
+	if runtime.GOOS == OSWindows {
+		t.Skip("signals are not supported on windows")
+	}
+
+	err := exec.Command("sh", "-c", "kill -9 $$").Run()
+	sig, signaled, coreDump := exitSignal(err)
+	assert.True(t, signaled)
+	assert.Equal(t, syscall.SIGKILL, sig)
+	assert.False(t, coreDump)
+
+	_, signaled, _ = exitSignal(exec.Command("sh", "-c", "exit 3").Run())
+	assert.False(t, signaled)
+
+	_, signaled, _ = exitSignal(errors.New("not an exit"))
+	assert.False(t, signaled)
+}
+
+func TestPanicDetector(t *testing.T) {
+	testCases := []struct {
+		name     string
+		writes   []string
+		expected string
+	}{
+		{
+			name:     "panic",
+			writes:   []string{"some log\n", "panic: boom\n\ngoroutine 1 [running]:\n"},
+			expected: "panic: boom",
+		},
+		{
+			name:     "split writes",
+			writes:   []string{"pan", "ic: runtime error: index out", " of range\n"},
+			expected: "panic: runtime error: index out of range",
+		},
+		{
+			name:     "fatal error",
+			writes:   []string{"fatal error: all goroutines are asleep - deadlock!\n"},
+			expected: "fatal error: all goroutines are asleep - deadlock!",
+		},
+		{
+			name:     "no panic",
+			writes:   []string{"the panic: is not at the beginning\n", "panic: without line break"},
+			expected: "",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			d := &panicDetector{}
+			for _, w := range tc.writes {
+				n, err := d.Write([]byte(w))
+				assert.Nil(t, err)
+				assert.Equal(t, len(w), n)
+			}
+			assert.Equal(t, tc.expected, d.panicLine())
+		})
+	}
+}
+
+func TestKilledByOOM(t *testing.T) {
+	if runtime.GOOS == OSWindows {
+		t.Skip("signals are not supported on windows")
+	}
+
+	dir, err := ioutil.TempDir("", "gaper-oom")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir) // nolint errcheck
+
+	eventsFile := filepath.Join(dir, "memory.events")
+	if err := ioutil.WriteFile(eventsFile, []byte("low 0\nhigh 0\nmax 0\noom 1\noom_kill 1\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	defaultOOMKillFiles := oomKillFiles
+	oomKillFiles = []string{filepath.Join(dir, "missing"), eventsFile}
+	defer func() { oomKillFiles = defaultOOMKillFiles }()
+
+	count, ok := readOOMKills()
+	assert.True(t, ok)
+	assert.Equal(t, 1, count)
+
+	killed := exec.Command("sh", "-c", "kill -9 $$").Run()
+	assert.True(t, killedByOOM(killed, -1, 0, true), "oom_kill count increased")
+	assert.False(t, killedByOOM(killed, -1, 1, true), "oom_kill count unchanged")
+	assert.False(t, killedByOOM(killed, -1, 0, false), "not guessed without the oom_kill count")
+	assert.True(t, killedByOOM(errors.New("exit status 137"), exitCodeKilled, 0, true), "killed by the shell")
+
+	failed := exec.Command("sh", "-c", "exit 1").Run()
+	assert.False(t, killedByOOM(failed, 1, 0, true), "not killed")
+}
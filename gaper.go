@@ -3,7 +3,10 @@
 package gaper
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"io"
 	"os"
 	"os/signal"
 	"path/filepath"
@@ -30,116 +33,497 @@ var (
 	NoRestartOnExit    = "exit"
 )
 
+// errDebugRunCommand is returned when the debug mode is set along with a run command
+var errDebugRunCommand = errors.New("debug mode requires the programs to be built and run by gaper, " +
+	"it can't be used with a run command")
+
 // exit statuses
 var exitStatusSuccess = 0
 var exitStatusError = 1
 
 // Config contains all settings supported by gaper
 type Config struct {
-	BinName              string
-	BuildPath            string
-	BuildArgs            []string
-	BuildArgsMerged      string
-	ProgramArgs          []string
-	ProgramArgsMerged    string
-	WatchItems           []string
-	IgnoreItems          []string
-	PollInterval         int
-	Extensions           []string
-	NoRestartOn          string
-	DisableDefaultIgnore bool
-	WorkingDirectory     string
+	BinName              string          `yaml:"bin_name"`
+	BuildPath            string          `yaml:"build_path"`
+	BuildArgs            []string        `yaml:"-"`
+	BuildArgsMerged      string          `yaml:"build_args"`
+	ProgramArgs          []string        `yaml:"-"`
+	ProgramArgsMerged    string          `yaml:"program_args"`
+	BuildCommand         []string        `yaml:"-"`
+	BuildCommandMerged   string          `yaml:"build_command"`
+	RunCommand           []string        `yaml:"-"`
+	RunCommandMerged     string          `yaml:"run_command"`
+	ExecWrapper          []string        `yaml:"-"`
+	ExecWrapperMerged    string          `yaml:"exec_wrapper"`
+	WatchItems           []string        `yaml:"watch"`
+	IgnoreItems          []string        `yaml:"ignore"`
+	PollInterval         int             `yaml:"poll_interval"`
+	Extensions           []string        `yaml:"extensions"`
+	NoRestartOn          string          `yaml:"no_restart_on"`
+	RestartPolicy        string          `yaml:"restart_policy"`
+	DisableDefaultIgnore bool            `yaml:"disable_default_ignore"`
+	DisableWorkspace     bool            `yaml:"disable_workspace"`
+	OutputPrefix         string          `yaml:"output_prefix"`
+	OutputColor          string          `yaml:"output_color"`
+	OutputTimestamp      bool            `yaml:"output_timestamp"`
+	OutputLogDir         string          `yaml:"output_log_dir"`
+	OutputLogRetention   int             `yaml:"output_log_retention"`
+	DisableKeyboard      bool            `yaml:"disable_keyboard"`
+	PauseFiles           []string        `yaml:"pause_files"`
+	DisableGitPause      bool            `yaml:"disable_git_pause"`
+	ControlAddr          string          `yaml:"control_addr"`
+	Stdin                bool            `yaml:"stdin"`
+	PTY                  bool            `yaml:"pty"`
+	EscapeKey            string          `yaml:"escape_key"`
+	ForwardSignals       []string        `yaml:"forward_signals"`
+	SignalProcessGroup   bool            `yaml:"signal_process_group"`
+	RestartOnSIGHUP      bool            `yaml:"restart_on_sighup"`
+	ExitWithProgram      bool            `yaml:"exit_with_program"`
+	Once                 bool            `yaml:"once"`
+	Debug                bool            `yaml:"debug"`
+	Race                 bool            `yaml:"race"`
+	Cover                bool            `yaml:"cover"`
+	CoverDir             string          `yaml:"cover_dir"`
+	DiagnosticsFile      string          `yaml:"diagnostics_file"`
+	DiagnosticsFormat    string          `yaml:"diagnostics_format"`
+	DebugAddr            string          `yaml:"debug_addr"`
+	WorkingDirectory     string          `yaml:"-"`
+	Services             []ServiceConfig `yaml:"services"`
+	// restart policy used instead of the one from RestartPolicy or NoRestartOn,
+	// by the program and the services without their own restart settings
+	CustomRestartPolicy RestartPolicy `yaml:"-"`
+	// keeps the statistics of every build and restart cycle when set
+	History *History `yaml:"-"`
+	// commands requested while the programs are supervised
+	Commands chan Command `yaml:"-"`
+	// events published while the programs are supervised
+	events *eventBus
+	// where the programs output is written to instead of os.Stdout and os.Stderr
+	stdout io.Writer
+	stderr io.Writer
+	// forwards the input read by gaper to the program
+	input *inputForwarder
+	// signals forwarded to the programs
+	signals chan os.Signal
+}
+
+// programOutput returns the writers for the programs output
+func (cfg *Config) programOutput() (io.Writer, io.Writer) {
+	stdout, stderr := cfg.stdout, cfg.stderr
+	if stdout == nil {
+		stdout = os.Stdout
+	}
+	if stderr == nil {
+		stderr = os.Stderr
+	}
+
+	return stdout, stderr
 }
 
+// programInput returns the input forwarder for a program, which is
+// nil when the input is not forwarded to the program
+func (cfg *Config) programInput(forward bool) *inputForwarder {
+	if !forward {
+		return nil
+	}
+
+	if cfg.input == nil {
+		cfg.input = &inputForwarder{}
+	}
+
+	return cfg.input
+}
+
+// inputSettings checks if the input is forwarded to any
+// program and if that program runs in a pseudo-terminal
+func (cfg *Config) inputSettings() (bool, bool) {
+	if len(cfg.Services) == 0 {
+		return cfg.Stdin || cfg.PTY, cfg.PTY
+	}
+
+	for _, svc := range cfg.Services {
+		if svc.Stdin || svc.PTY {
+			return true, svc.PTY
+		}
+	}
+
+	return false, false
+}
+
+// eventsBatchWindow is the time waited for other file changes
+// detected in the same scan after receiving a change event
+var eventsBatchWindow = 100 * time.Millisecond
+
 // Run starts the whole gaper process watching for file changes or exit codes
 // and restarting the program
 func Run(cfg *Config, chOSSiginal chan os.Signal) error {
 	logger.Debug("Starting gaper")
 
-	if err := setupConfig(cfg); err != nil {
+	// listen for OS signals
+	signal.Notify(chOSSiginal, os.Interrupt, syscall.SIGTERM)
+
+	s := New(cfg)
+
+	forwarded, err := parseSignals(cfg.ForwardSignals)
+	if err != nil {
 		return err
 	}
 
-	logger.Debugf("Config: %+v", cfg)
+	// forwarded signals go only to the programs, even the ones used by gaper
+	if len(forwarded) > 0 {
+		chForward := make(chan os.Signal, 1)
+		signal.Notify(chForward, forwarded...)
+		defer signal.Stop(chForward)
 
-	wCfg := WatcherConfig{
-		DefaultIgnore: !cfg.DisableDefaultIgnore,
-		PollInterval:  cfg.PollInterval,
-		WatchItems:    cfg.WatchItems,
-		IgnoreItems:   cfg.IgnoreItems,
-		Extensions:    cfg.Extensions,
+		go func() {
+			for sig := range chForward {
+				s.Signal(sig)
+			}
+		}()
 	}
 
-	builder := NewBuilder(cfg.BuildPath, cfg.BinName, cfg.WorkingDirectory, cfg.BuildArgs)
-	runner := NewRunner(os.Stdout, os.Stderr, filepath.Join(cfg.WorkingDirectory, builder.Binary()), cfg.ProgramArgs)
-	watcher, err := NewWatcher(wCfg)
-	if err != nil {
-		return fmt.Errorf("watcher error: %v", err)
+	// the pause signal toggles the watching
+	if pause := withoutSignals(pauseSignals, forwarded); len(pause) > 0 {
+		chPause := make(chan os.Signal, 1)
+		signal.Notify(chPause, pause...)
+		defer signal.Stop(chPause)
+
+		go func() {
+			for range chPause {
+				s.send(CommandTogglePause)
+			}
+		}()
+	}
+
+	// the restart signal forces a rebuild and restart of the programs
+	if cfg.RestartOnSIGHUP && restartSignal != nil && !containsSignal(forwarded, restartSignal) {
+		chRestart := make(chan os.Signal, 1)
+		signal.Notify(chRestart, restartSignal)
+		defer signal.Stop(chRestart)
+
+		go func() {
+			for range chRestart {
+				s.send(CommandRestart)
+			}
+		}()
 	}
 
-	return run(cfg, chOSSiginal, builder, runner, watcher)
+	forwardInput, pty := cfg.inputSettings()
+	switch {
+	case forwardInput:
+		// keyboard commands are read after the escape key, as the keys go to the program
+		escapeKey := cfg.EscapeKey
+		if escapeKey == "" {
+			escapeKey = DefaultEscapeKey
+		}
+		if cfg.DisableKeyboard {
+			escapeKey = ""
+		}
+
+		restore, err := startInput(cfg.programInput(true), escapeKey, pty, s.commands)
+		if err != nil {
+			return err
+		}
+		defer restore()
+	case !cfg.DisableKeyboard:
+		restore, err := startKeyboard(s.commands)
+		if err != nil {
+			logger.Warn("Error enabling keyboard commands:", err)
+		} else {
+			defer restore()
+		}
+	}
+
+	return s.run(context.Background(), chOSSiginal)
 }
 
-// nolint: gocyclo
 func run(cfg *Config, chOSSiginal chan os.Signal, builder Builder, runner Runner, watcher Watcher) error {
-	if err := builder.Build(); err != nil {
-		return fmt.Errorf("build error: %v", err)
+	policy, err := cfg.restartPolicy(nil)
+	if err != nil {
+		return err
 	}
 
-	// listen for OS signals
-	signal.Notify(chOSSiginal, os.Interrupt, syscall.SIGTERM)
+	svc := &service{builder: builder, runner: runner, restartPolicy: policy, history: cfg.History, events: cfg.events,
+		diagnostics: newDiagnosticsReporter(cfg)}
+	return runServices(context.Background(), cfg, chOSSiginal, []*service{svc}, watcher)
+}
 
-	if _, err := runner.Run(); err != nil {
-		return fmt.Errorf("run error: %v", err)
+// nolint: gocyclo
+func runServices(ctx context.Context, cfg *Config, chOSSiginal chan os.Signal, services []*service, watcher Watcher) error {
+	if err := launchServices(services); err != nil {
+		return err
 	}
 
-	// flag to know if an exit was caused by a restart from a file changing
-	changeRestart := false
+	exits := mergeExits(services)
+
+	notifier := newReadyNotifier(services)
+	defer notifier.close()
 
 	go watcher.Watch()
 	for {
 		select {
 		case event := <-watcher.Events():
-			logger.Debug("Detected new changed file:", event)
-			if changeRestart {
-				logger.Debug("Skip restart due to existing on going restart")
-				continue
-			}
-
-			changeRestart = runner.IsRunning()
+			events := collectEvents(event, watcher.Events())
+			logger.DebugWith("Detected new changed files", Field("files", events))
 
-			if err := restart(builder, runner); err != nil {
+			if err := handleChanges(services, events); err != nil {
 				return err
 			}
 		case err := <-watcher.Errors():
 			return fmt.Errorf("error on watching files: %v", err)
-		case err := <-runner.Errors():
-			logger.Debug("Detected program exit:", err)
+		case exit := <-exits:
+			svc := exit.service
+			logger.DebugWith("Detected program exit", Field("error", exit.err))
+
+			// an exit by a restart belongs to the cycle before the restart
+			cycle := svc.cycle
+			if svc.changeRestart {
+				cycle = svc.prevCycle
+			}
+
+			info := svc.exitInfo(exit.err, cycle)
+			svc.recordExit(cycle, info)
 
-			// ignore exit by change
-			if changeRestart {
-				changeRestart = false
+			// ignore exit by change or by a stop command
+			if svc.changeRestart || svc.stopped {
+				svc.changeRestart = false
+				logger.DebugWith(capitalize(programName(svc.name))+" "+info.Summary(), info.fields()...)
 				continue
 			}
 
-			if err = handleProgramExit(builder, runner, err, cfg.NoRestartOn); err != nil {
+			logger.InfoWith(capitalize(programName(svc.name))+" "+info.Summary(), info.fields()...)
+			if cfg.Once {
+				killServices(services)
+				return programExitError(info)
+			}
+
+			quit, err := handleProgramExit(services, svc, info, cfg.ExitWithProgram)
+			if err != nil {
+				return err
+			}
+
+			if quit {
+				killServices(services)
+				return programExitError(info)
+			}
+		case ready := <-notifier.ready:
+			if err := restartReadyDependents(services, ready); err != nil {
+				return err
+			}
+		case cmd := <-cfg.Commands:
+			quit, err := handleCommand(services, watcher, cfg.events, cmd)
+			if err != nil {
 				return err
 			}
+
+			if quit {
+				killServices(services)
+				return nil
+			}
+		case sig := <-cfg.signals:
+			forwardSignal(services, sig)
+		case <-ctx.Done():
+			logger.Debug("Stopping due to context cancellation")
+			killServices(services)
+			return nil
 		case signal := <-chOSSiginal:
 			logger.Debug("Got signal:", signal)
+			killServices(services)
+			return fmt.Errorf("OS signal: %v", signal)
+		}
+	}
+}
+
+// launchServices builds all programs and then runs them, starting the
+// first cycle of every service which the next restarts follow
+func launchServices(services []*service) error {
+	for _, svc := range services {
+		if err := svc.startCycle(CycleTriggerStart, nil).build(svc.builder); err != nil {
+			return svc.wrapError("build error", buildErrorMessage(err))
+		}
+	}
+
+	// services are sorted so dependencies are started first
+	for _, svc := range services {
+		svc.waitDependencies()
 
-			if err := runner.Kill(); err != nil {
-				logger.Error("Error killing:", err)
+		cmd, err := svc.runner.Run()
+		if err != nil {
+			return svc.wrapError("run error", err)
+		}
+
+		svc.cycle.started(cmd)
+		svc.finishCycle()
+	}
+
+	return nil
+}
+
+// mergeExits merges the exits from all programs in a single channel
+func mergeExits(services []*service) chan serviceExit {
+	exits := make(chan serviceExit)
+	for _, svc := range services {
+		go func(svc *service) {
+			for err := range svc.runner.Errors() {
+				exits <- serviceExit{service: svc, err: err}
 			}
+		}(svc)
+	}
 
-			return fmt.Errorf("OS signal: %v", signal)
-		default:
-			time.Sleep(time.Duration(cfg.PollInterval) * time.Millisecond)
+	return exits
+}
+
+// handleChanges restarts the services watching any of the changed files
+func handleChanges(services []*service, events []string) error {
+	for _, svc := range services {
+		if svc.stopped || !svc.watchesAny(events) {
+			continue
+		}
+
+		if svc.changeRestart {
+			logger.Debug("Skip restart due to existing on going restart")
+			continue
+		}
+
+		svc.changeRestart = svc.runner.IsRunning()
+
+		files := svc.watchedFiles(events)
+		logger.InfoWith("Restarting "+programName(svc.name)+" due to changed files", Field("files", files))
+		svc.events.publish(Event{Type: EventChange, Service: svc.name, Files: files})
+		svc.startCycle(CycleTriggerChange, files)
+		if err := restartService(services, svc); err != nil {
+			return err
 		}
 	}
+
+	return nil
+}
+
+// forwardSignal sends the signal to the running programs
+func forwardSignal(services []*service, sig os.Signal) {
+	for _, svc := range services {
+		if svc.stopped {
+			continue
+		}
+
+		logger.DebugWith("Forwarding signal to "+programName(svc.name), Field("signal", sig.String()))
+		if err := svc.runner.Signal(sig); err != nil {
+			logger.Error("Error forwarding signal:", err)
+		}
+	}
+}
+
+// killServices kills the programs of all services
+func killServices(services []*service) {
+	for _, svc := range services {
+		if err := svc.runner.Kill(); err != nil {
+			logger.Error("Error killing:", err)
+		}
+	}
+}
+
+// handleCommand applies a command requested while the programs are supervised,
+// returning true when gaper should quit
+func handleCommand(services []*service, watcher Watcher, events *eventBus, cmd Command) (bool, error) { // nolint gocyclo
+	logger.Debug("Got command:", cmd)
+
+	switch cmd {
+	case CommandRestart:
+		logger.Info("Restarting all programs")
+		return false, startServices(services, false)
+	case CommandToggle:
+		for _, svc := range services {
+			if svc.stopped {
+				logger.Info("Starting stopped programs")
+				return false, startServices(services, true)
+			}
+		}
+
+		logger.Info("Stopping all programs, press \"s\" to start them again")
+		return false, stopServices(services)
+	case CommandStop:
+		logger.Info("Stopping all programs")
+		return false, stopServices(services)
+	case CommandStart:
+		logger.Info("Starting stopped programs")
+		return false, startServices(services, true)
+	case CommandTogglePause:
+		if watcher.Paused() {
+			return handleCommand(services, watcher, events, CommandResume)
+		}
+
+		return handleCommand(services, watcher, events, CommandPause)
+	case CommandPause:
+		logger.Info("Paused watching for changes")
+		watcher.Pause()
+		events.publish(Event{Type: EventPause})
+	case CommandResume:
+		// the changes made while paused are emitted at once by the watcher
+		logger.Info("Resumed watching for changes")
+		watcher.Resume()
+		events.publish(Event{Type: EventResume})
+	case CommandClear:
+		fmt.Fprint(os.Stdout, clearScreen) // nolint errcheck
+	case CommandVerbose:
+		logger.Verbose(!logger.IsVerbose())
+		logger.Info("Verbose messages enabled:", logger.IsVerbose())
+	case CommandQuit:
+		logger.Info("Quitting")
+		return true, nil
+	default:
+		logger.Warnf("Unknown command \"%s\"", cmd)
+	}
+
+	return false, nil
+}
+
+// stopServices stops the programs of all services, the dependents before their dependencies
+func stopServices(services []*service) error {
+	for i := len(services) - 1; i >= 0; i-- {
+		if err := services[i].stop(); err != nil {
+			return services[i].wrapError("stop error", err)
+		}
+	}
+
+	return nil
 }
 
-func restart(builder Builder, runner Runner) error {
+// startServices rebuilds and restarts the programs of the services in order,
+// when onlyStopped is set only the services stopped by a command are started
+func startServices(services []*service, onlyStopped bool) error {
+	for _, svc := range services {
+		if onlyStopped && !svc.stopped {
+			continue
+		}
+
+		svc.stopped = false
+		svc.changeRestart = svc.runner.IsRunning() && !svc.runner.Exited()
+		svc.waitDependencies()
+		if err := restart(svc.builder, svc.runner, svc.startCycle(CycleTriggerCommand, nil)); err != nil {
+			return err
+		}
+		svc.finishCycle()
+	}
+
+	return nil
+}
+
+// collectEvents gathers the other file changes emitted right after the first event,
+// so changes detected in the same scan are handled at once
+func collectEvents(first string, events chan string) []string {
+	result := []string{first}
+	for {
+		select {
+		case event := <-events:
+			result = append(result, event)
+		case <-time.After(eventsBatchWindow):
+			return result
+		}
+	}
+}
+
+// restart kills the program, builds and runs it again recording the timing on the cycle
+func restart(builder Builder, runner Runner, cycle *Cycle) error {
 	logger.Debug("Restarting program")
 
 	// kill process if it is running
@@ -149,38 +533,140 @@ func restart(builder Builder, runner Runner) error {
 		}
 	}
 
-	if err := builder.Build(); err != nil {
-		logger.Error("Error building binary during a restart:", err)
+	if err := cycle.build(builder); err != nil {
+		logger.Error("Error building binary during a restart:", buildErrorMessage(err))
 		return nil
 	}
 
-	if _, err := runner.Run(); err != nil {
+	cmd, err := runner.Run()
+	if err != nil {
 		logger.Error("Error starting process during a restart:", err)
 		return nil
 	}
 
+	cycle.started(cmd)
 	return nil
 }
 
-func handleProgramExit(builder Builder, runner Runner, err error, noRestartOn string) error {
-	exitStatus := runner.ExitStatus(err)
+// restartService restarts the program of a service on the cycle started by the caller
+// with the trigger of the restart. The services set to restart along with it are
+// restarted later by the run loop, once the services they depend on are ready.
+func restartService(services []*service, svc *service) error {
+	if err := restart(svc.builder, svc.runner, svc.cycle); err != nil {
+		return err
+	}
+	svc.finishCycle()
 
-	// if "error", an exit code of 0 will still restart.
-	if noRestartOn == NoRestartOnError && exitStatus == exitStatusError {
-		return nil
+	if planDependentRestarts(services, svc) {
+		svc.notifyReady()
 	}
 
-	// if "success", no restart only if exit code is 0.
-	if noRestartOn == NoRestartOnSuccess && exitStatus == exitStatusSuccess {
-		return nil
+	return nil
+}
+
+// planDependentRestarts marks the services depending on the restarted service, directly
+// or through other dependents, to be restarted once the services they depend on
+// among them are ready, so each one is restarted only once. Returns false when
+// there are no dependents to restart.
+func planDependentRestarts(services []*service, svc *service) bool {
+	restarted := map[string]bool{svc.name: true}
+	planned := false
+
+	// services are sorted so the dependencies come before their dependents
+	for _, dependent := range services {
+		if dependent == svc || dependent.stopped || !dependent.restartWithDeps {
+			continue
+		}
+
+		waitingFor := map[string]bool{}
+		for _, dep := range dependent.dependencies {
+			if restarted[dep.name] {
+				waitingFor[dep.name] = true
+			}
+		}
+
+		if len(waitingFor) > 0 {
+			dependent.waitingFor = waitingFor
+			restarted[dependent.name] = true
+			planned = true
+		}
 	}
 
-	// if "exit", no restart regardless of exit code.
-	if noRestartOn == NoRestartOnExit {
+	return planned
+}
+
+// restartReadyDependents restarts the dependents which were only waiting
+// for the ready service, which are then waited for by their own dependents
+func restartReadyDependents(services []*service, ready serviceReady) error {
+	// a service restarted again notifies once it is ready after the last restart
+	if ready.service.cycle != ready.cycle {
 		return nil
 	}
 
-	return restart(builder, runner)
+	for _, dependent := range services {
+		if !dependent.waitingFor[ready.service.name] {
+			continue
+		}
+
+		delete(dependent.waitingFor, ready.service.name)
+		if len(dependent.waitingFor) > 0 {
+			continue
+		}
+
+		dependent.waitingFor = nil
+		if dependent.stopped {
+			continue
+		}
+
+		logger.Info("Restarting " + programName(dependent.name) + " due to its dependency " + ready.service.name)
+		dependent.changeRestart = dependent.runner.IsRunning() && !dependent.runner.Exited()
+		if err := restart(dependent.builder, dependent.runner, dependent.startCycle(CycleTriggerDependency, nil)); err != nil {
+			return err
+		}
+		dependent.finishCycle()
+
+		if isWaitedFor(services, dependent) {
+			dependent.notifyReady()
+		}
+	}
+
+	return nil
+}
+
+// isWaitedFor checks if any service waits for the service to be ready before restarting
+func isWaitedFor(services []*service, svc *service) bool {
+	for _, dependent := range services {
+		if dependent.waitingFor[svc.name] {
+			return true
+		}
+	}
+
+	return false
+}
+
+// handleProgramExit applies the restart policy of the service to the exit of its
+// program, returning true when gaper should quit. With exitWithProgram gaper quits
+// instead of waiting for changes to restart the program.
+func handleProgramExit(services []*service, svc *service, exit ExitInfo, exitWithProgram bool) (bool, error) {
+	policy := svc.restartPolicy
+	if policy == nil {
+		policy = AlwaysRestart{}
+	}
+
+	switch policy.Decide(exit) {
+	case RestartWait:
+		if exitWithProgram {
+			return true, nil
+		}
+
+		logger.Info("Waiting for changes to restart " + programName(svc.name))
+		return false, nil
+	case RestartQuit:
+		return true, nil
+	}
+
+	svc.startCycle(CycleTriggerExit, nil)
+	return false, restartService(services, svc)
 }
 
 func setupConfig(cfg *Config) error {
@@ -190,6 +676,10 @@ func setupConfig(cfg *Config) error {
 		cfg.BuildPath = DefaultBuildPath
 	}
 
+	if cfg.PollInterval == 0 {
+		cfg.PollInterval = DefaultPoolInterval
+	}
+
 	cfg.BuildArgs, err = parseInnerArgs(cfg.BuildArgs, cfg.BuildArgsMerged)
 	if err != nil {
 		return err
@@ -200,13 +690,91 @@ func setupConfig(cfg *Config) error {
 		return err
 	}
 
-	cfg.WorkingDirectory, err = os.Getwd()
+	cfg.ExecWrapper, err = parseInnerArgs(cfg.ExecWrapper, cfg.ExecWrapperMerged)
 	if err != nil {
 		return err
 	}
 
-	if len(cfg.WatchItems) == 0 {
-		cfg.WatchItems = append(cfg.WatchItems, cfg.BuildPath)
+	cfg.BuildCommand, err = parseInnerArgs(cfg.BuildCommand, cfg.BuildCommandMerged)
+	if err != nil {
+		return err
+	}
+
+	cfg.RunCommand, err = parseInnerArgs(cfg.RunCommand, cfg.RunCommandMerged)
+	if err != nil {
+		return err
+	}
+
+	if cfg.Debug && len(cfg.RunCommand) > 0 {
+		return errDebugRunCommand
+	}
+
+	if _, err := cfg.restartPolicy(nil); err != nil {
+		return err
+	}
+
+	forwarded, err := parseSignals(cfg.ForwardSignals)
+	if err != nil {
+		return err
+	}
+
+	if cfg.RestartOnSIGHUP && restartSignal != nil && containsSignal(forwarded, restartSignal) {
+		return errors.New("SIGHUP can't be forwarded to the programs while it restarts them")
+	}
+
+	if cfg.EscapeKey != "" {
+		if _, err := parseEscapeKey(cfg.EscapeKey); err != nil {
+			return err
+		}
+	}
+
+	if cfg.Debug {
+		if err := checkDebugger(); err != nil {
+			return err
+		}
+
+		if cfg.DebugAddr == "" {
+			cfg.DebugAddr = DefaultDebugAddr
+		}
+
+		if _, err := serviceDebugAddr(cfg.DebugAddr, 0); err != nil {
+			return err
+		}
+	}
+
+	if cfg.WorkingDirectory == "" {
+		cfg.WorkingDirectory, err = os.Getwd()
+		if err != nil {
+			return err
+		}
+	}
+
+	if cfg.OutputLogDir != "" && !filepath.IsAbs(cfg.OutputLogDir) {
+		cfg.OutputLogDir = filepath.Join(cfg.WorkingDirectory, cfg.OutputLogDir)
+	}
+
+	if cfg.Cover {
+		if cfg.CoverDir == "" {
+			cfg.CoverDir = DefaultCoverDir
+		}
+
+		if !filepath.IsAbs(cfg.CoverDir) {
+			cfg.CoverDir = filepath.Join(cfg.WorkingDirectory, cfg.CoverDir)
+		}
+	}
+
+	if cfg.DiagnosticsFile != "" {
+		if cfg.DiagnosticsFormat, err = resolveDiagnosticsFormat(cfg.DiagnosticsFormat, cfg.DiagnosticsFile); err != nil {
+			return err
+		}
+
+		if !filepath.IsAbs(cfg.DiagnosticsFile) {
+			cfg.DiagnosticsFile = filepath.Join(cfg.WorkingDirectory, cfg.DiagnosticsFile)
+		}
+	}
+
+	if cfg.OutputLogRetention == 0 {
+		cfg.OutputLogRetention = DefaultOutputLogRetention
 	}
 
 	var extensions []string
@@ -216,6 +784,24 @@ func setupConfig(cfg *Config) error {
 	}
 	cfg.Extensions = extensions
 
+	if len(cfg.Services) > 0 {
+		return setupServices(cfg)
+	}
+
+	if len(cfg.WatchItems) == 0 {
+		cfg.WatchItems = append(cfg.WatchItems, cfg.BuildPath)
+	}
+
+	// watch local modules the program depends on (replace directives and go.work)
+	moduleDirs, err := localModuleDirs(cfg.BuildPath, !cfg.DisableWorkspace)
+	if err != nil {
+		return err
+	}
+
+	for _, dir := range moduleDirs {
+		cfg.WatchItems = append(cfg.WatchItems, relativePath(cfg.WorkingDirectory, dir))
+	}
+
 	return nil
 }
 
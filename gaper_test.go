@@ -1,7 +1,9 @@
 package gaper
 
 import (
+	"context"
 	"errors"
+	"net"
 	"os"
 	"os/exec"
 	"path/filepath"
@@ -39,6 +41,19 @@ func TestGaperSetupConfigNoParams(t *testing.T) {
 	assert.Equal(t, args.WatchItems, []string{"."})
 }
 
+func TestGaperSetupConfigLocalModules(t *testing.T) {
+	args := &Config{
+		BuildPath: filepath.Join("testdata", "modules", "app"),
+	}
+	err := setupConfig(args)
+	assert.Nil(t, err, "build error")
+	assert.Equal(t, []string{
+		filepath.Join("testdata", "modules", "app"),
+		filepath.Join("testdata", "modules", "lib"),
+		filepath.Join("testdata", "modules", "worker"),
+	}, args.WatchItems)
+}
+
 func TestGaperBuildError(t *testing.T) {
 	mockBuilder := new(testdata.MockBuilder)
 	mockBuilder.On("Build").Return(errors.New("build-error"))
@@ -104,6 +119,182 @@ func TestGaperWatcherError(t *testing.T) {
 	mockWatcher.AssertExpectations(t)
 }
 
+func TestGaperRunLoopWaitsWithoutPollInterval(t *testing.T) {
+	mockBuilder := new(testdata.MockBuilder)
+	mockBuilder.On("Build").Return(nil)
+
+	mockRunner := new(testdata.MockRunner)
+	mockRunner.On("Run").Return(&exec.Cmd{}, nil)
+	mockRunner.On("Kill").Return(nil)
+	mockRunner.On("Errors").Return(make(chan error))
+
+	mockWatcher := new(testdata.MockWacther)
+	mockWatcher.On("Errors").Return(make(chan error))
+	mockWatcher.On("Events").Return(make(chan string))
+
+	chOSSiginal := make(chan os.Signal, 2)
+	go func() {
+		time.Sleep(300 * time.Millisecond)
+		chOSSiginal <- syscall.SIGTERM
+	}()
+
+	// the loop blocks until something happens instead of checking the channels over and over
+	err := run(&Config{PollInterval: 0}, chOSSiginal, mockBuilder, mockRunner, mockWatcher)
+	assert.NotNil(t, err, "signal error")
+	assert.True(t, len(mockWatcher.Calls) < 10, "run loop checked the channels too often")
+}
+
+func TestGaperHistory(t *testing.T) {
+	mockBuilder := new(testdata.MockBuilder)
+	mockBuilder.On("Build").Return(nil)
+
+	mockRunner := new(testdata.MockRunner)
+	runnerErrorsChan := make(chan error)
+	mockRunner.On("Run").Return(&exec.Cmd{}, nil)
+	mockRunner.On("Kill").Return(nil)
+	mockRunner.On("Errors").Return(runnerErrorsChan)
+	mockRunner.On("Exited").Return(false)
+	mockRunner.On("IsRunning").Return(false)
+
+	mockWatcher := new(testdata.MockWacther)
+	watcherEvetnsChan := make(chan string)
+	mockWatcher.On("Errors").Return(make(chan error))
+	mockWatcher.On("Events").Return(watcherEvetnsChan)
+
+	cfg := &Config{History: NewHistory(0)}
+
+	chOSSiginal := make(chan os.Signal, 2)
+	go func() {
+		time.Sleep(1 * time.Second)
+		watcherEvetnsChan <- "main.go"
+		watcherEvetnsChan <- "handler.go"
+		time.Sleep(1 * time.Second)
+		chOSSiginal <- syscall.SIGINT
+	}()
+
+	err := run(cfg, chOSSiginal, mockBuilder, mockRunner, mockWatcher)
+	assert.Equal(t, "OS signal: interrupt", err.Error())
+
+	cycles := cfg.History.Cycles()
+	assert.Len(t, cycles, 2)
+	assert.Equal(t, CycleTriggerStart, cycles[0].Trigger)
+	assert.Equal(t, CycleTriggerChange, cycles[1].Trigger)
+	assert.Equal(t, []string{"main.go", "handler.go"}, cycles[1].Files)
+	assert.False(t, cycles[1].ProgramStartedAt.IsZero())
+}
+
+func TestGaperCommands(t *testing.T) {
+	mockBuilder := new(testdata.MockBuilder)
+	mockBuilder.On("Build").Return(nil)
+
+	mockRunner := new(testdata.MockRunner)
+	mockRunner.On("Run").Return(&exec.Cmd{}, nil)
+	mockRunner.On("Kill").Return(nil)
+	mockRunner.On("Errors").Return(make(chan error))
+	mockRunner.On("Exited").Return(false)
+	mockRunner.On("IsRunning").Return(true)
+
+	mockWatcher := new(testdata.MockWacther)
+	mockWatcher.On("Errors").Return(make(chan error))
+	mockWatcher.On("Events").Return(make(chan string))
+
+	cfg := &Config{Commands: make(chan Command)}
+	go func() {
+		cfg.Commands <- CommandRestart
+		// stop and start again
+		cfg.Commands <- CommandToggle
+		cfg.Commands <- CommandToggle
+		cfg.Commands <- CommandQuit
+	}()
+
+	err := run(cfg, make(chan os.Signal, 2), mockBuilder, mockRunner, mockWatcher)
+	assert.Nil(t, err, "quit error")
+	mockBuilder.AssertNumberOfCalls(t, "Build", 3)
+	mockRunner.AssertNumberOfCalls(t, "Run", 3)
+	mockRunner.AssertNumberOfCalls(t, "Kill", 4)
+}
+
+func TestGaperForwardSignal(t *testing.T) {
+	mockBuilder := new(testdata.MockBuilder)
+	mockBuilder.On("Build").Return(nil)
+
+	mockRunner := new(testdata.MockRunner)
+	mockRunner.On("Run").Return(&exec.Cmd{}, nil)
+	mockRunner.On("Kill").Return(nil)
+	mockRunner.On("Errors").Return(make(chan error))
+	mockRunner.On("Signal", os.Interrupt).Return(nil)
+
+	mockWatcher := new(testdata.MockWacther)
+	mockWatcher.On("Errors").Return(make(chan error))
+	mockWatcher.On("Events").Return(make(chan string))
+
+	cfg := &Config{Commands: make(chan Command), signals: make(chan os.Signal)}
+	go func() {
+		cfg.signals <- os.Interrupt
+		cfg.Commands <- CommandQuit
+	}()
+
+	err := run(cfg, make(chan os.Signal, 2), mockBuilder, mockRunner, mockWatcher)
+	assert.Nil(t, err, "quit error")
+	mockRunner.AssertNumberOfCalls(t, "Signal", 1)
+	// forwarding a signal doesn't restart the program
+	mockRunner.AssertNumberOfCalls(t, "Run", 1)
+}
+
+func TestGaperPauseResume(t *testing.T) {
+	mockBuilder := new(testdata.MockBuilder)
+	mockBuilder.On("Build").Return(nil)
+
+	mockRunner := new(testdata.MockRunner)
+	mockRunner.On("Run").Return(&exec.Cmd{}, nil)
+	mockRunner.On("Kill").Return(nil)
+	mockRunner.On("Errors").Return(make(chan error))
+
+	mockWatcher := new(testdata.MockWacther)
+	mockWatcher.On("Errors").Return(make(chan error))
+	mockWatcher.On("Events").Return(make(chan string))
+	mockWatcher.On("Pause").Return()
+	mockWatcher.On("Resume").Return()
+
+	cfg := &Config{Commands: make(chan Command)}
+	go func() {
+		cfg.Commands <- CommandPause
+		cfg.Commands <- CommandResume
+		cfg.Commands <- CommandQuit
+	}()
+
+	err := run(cfg, make(chan os.Signal, 2), mockBuilder, mockRunner, mockWatcher)
+	assert.Nil(t, err, "quit error")
+	mockWatcher.AssertNumberOfCalls(t, "Pause", 1)
+	mockWatcher.AssertNumberOfCalls(t, "Resume", 1)
+}
+
+func TestGaperTogglePause(t *testing.T) {
+	mockBuilder := new(testdata.MockBuilder)
+	mockBuilder.On("Build").Return(nil)
+
+	mockRunner := new(testdata.MockRunner)
+	mockRunner.On("Run").Return(&exec.Cmd{}, nil)
+	mockRunner.On("Kill").Return(nil)
+	mockRunner.On("Errors").Return(make(chan error))
+
+	mockWatcher := new(testdata.MockWacther)
+	mockWatcher.On("Errors").Return(make(chan error))
+	mockWatcher.On("Events").Return(make(chan string))
+	mockWatcher.On("Paused").Return(false)
+	mockWatcher.On("Pause").Return()
+
+	cfg := &Config{Commands: make(chan Command)}
+	go func() {
+		cfg.Commands <- CommandTogglePause
+		cfg.Commands <- CommandQuit
+	}()
+
+	err := run(cfg, make(chan os.Signal, 2), mockBuilder, mockRunner, mockWatcher)
+	assert.Nil(t, err, "quit error")
+	mockWatcher.AssertNumberOfCalls(t, "Pause", 1)
+}
+
 func TestGaperProgramExit(t *testing.T) {
 	testCases := []struct {
 		name        string
@@ -194,6 +385,376 @@ func TestGaperProgramExit(t *testing.T) {
 	}
 }
 
+func TestGaperRestartPolicyMaxRetries(t *testing.T) {
+	mockBuilder := new(testdata.MockBuilder)
+	mockBuilder.On("Build").Return(nil)
+
+	mockRunner := new(testdata.MockRunner)
+	runnerErrorsChan := make(chan error)
+	mockRunner.On("Run").Return(&exec.Cmd{}, nil)
+	mockRunner.On("Kill").Return(nil)
+	mockRunner.On("Errors").Return(runnerErrorsChan)
+	mockRunner.On("ExitStatus").Return(2)
+	mockRunner.On("Exited").Return(true)
+
+	mockWatcher := new(testdata.MockWacther)
+	mockWatcher.On("Errors").Return(make(chan error))
+	mockWatcher.On("Events").Return(make(chan string))
+
+	cfg := &Config{RestartPolicy: "max-retries:2", Commands: make(chan Command)}
+	go func() {
+		for i := 0; i < 4; i++ {
+			runnerErrorsChan <- errors.New("exit status 2")
+		}
+		cfg.Commands <- CommandQuit
+	}()
+
+	err := run(cfg, make(chan os.Signal, 2), mockBuilder, mockRunner, mockWatcher)
+	assert.Nil(t, err, "quit error")
+
+	// started once and restarted twice
+	mockRunner.AssertNumberOfCalls(t, "Run", 3)
+}
+
+func TestGaperRestartPolicyQuit(t *testing.T) {
+	mockBuilder := new(testdata.MockBuilder)
+	mockBuilder.On("Build").Return(nil)
+
+	mockRunner := new(testdata.MockRunner)
+	runnerErrorsChan := make(chan error)
+	mockRunner.On("Run").Return(&exec.Cmd{}, nil)
+	mockRunner.On("Kill").Return(nil)
+	mockRunner.On("Errors").Return(runnerErrorsChan)
+	mockRunner.On("ExitStatus").Return(0)
+
+	mockWatcher := new(testdata.MockWacther)
+	mockWatcher.On("Errors").Return(make(chan error))
+	mockWatcher.On("Events").Return(make(chan string))
+
+	cfg := &Config{
+		CustomRestartPolicy: RestartPolicyFunc(func(exit ExitInfo) RestartDecision {
+			return RestartQuit
+		}),
+	}
+	go func() {
+		runnerErrorsChan <- nil
+	}()
+
+	err := run(cfg, make(chan os.Signal, 2), mockBuilder, mockRunner, mockWatcher)
+	assert.Nil(t, err, "quit error")
+	mockRunner.AssertNumberOfCalls(t, "Run", 1)
+}
+
+func TestGaperExitWithProgram(t *testing.T) {
+	mockBuilder := new(testdata.MockBuilder)
+	mockBuilder.On("Build").Return(nil)
+
+	mockRunner := new(testdata.MockRunner)
+	runnerErrorsChan := make(chan error)
+	mockRunner.On("Run").Return(&exec.Cmd{}, nil)
+	mockRunner.On("Kill").Return(nil)
+	mockRunner.On("Errors").Return(runnerErrorsChan)
+	mockRunner.On("Exited").Return(true)
+	mockRunner.On("ExitStatus").Return(3).Once()
+	mockRunner.On("ExitStatus").Return(0)
+
+	mockWatcher := new(testdata.MockWacther)
+	mockWatcher.On("Errors").Return(make(chan error))
+	mockWatcher.On("Events").Return(make(chan string))
+
+	cfg := &Config{
+		RestartPolicy:   RestartPolicyOnFailure,
+		ExitWithProgram: true,
+	}
+	go func() {
+		// restarted on the failure and then quits on the success
+		runnerErrorsChan <- errors.New("exit status 3")
+		runnerErrorsChan <- nil
+	}()
+
+	err := run(cfg, make(chan os.Signal, 2), mockBuilder, mockRunner, mockWatcher)
+	assert.Nil(t, err, "quit error")
+	mockRunner.AssertNumberOfCalls(t, "Run", 2)
+}
+
+func TestGaperExitWithProgramExitCode(t *testing.T) {
+	mockBuilder := new(testdata.MockBuilder)
+	mockBuilder.On("Build").Return(nil)
+
+	mockRunner := new(testdata.MockRunner)
+	runnerErrorsChan := make(chan error)
+	mockRunner.On("Run").Return(&exec.Cmd{}, nil)
+	mockRunner.On("Kill").Return(nil)
+	mockRunner.On("Errors").Return(runnerErrorsChan)
+	mockRunner.On("ExitStatus").Return(3)
+
+	mockWatcher := new(testdata.MockWacther)
+	mockWatcher.On("Errors").Return(make(chan error))
+	mockWatcher.On("Events").Return(make(chan string))
+
+	cfg := &Config{
+		NoRestartOn:     NoRestartOnExit,
+		ExitWithProgram: true,
+	}
+	go func() {
+		runnerErrorsChan <- errors.New("exit status 3")
+	}()
+
+	err := run(cfg, make(chan os.Signal, 2), mockBuilder, mockRunner, mockWatcher)
+	exitErr, ok := err.(*ProgramExitError)
+	assert.True(t, ok, "program exit error")
+	assert.Equal(t, 3, exitErr.ExitCode())
+	assert.Contains(t, exitErr.Error(), "program exited with code 3")
+	mockRunner.AssertNumberOfCalls(t, "Run", 1)
+}
+
+func TestGaperOnce(t *testing.T) {
+	mockBuilder := new(testdata.MockBuilder)
+	mockBuilder.On("Build").Return(nil)
+
+	mockRunner := new(testdata.MockRunner)
+	runnerErrorsChan := make(chan error)
+	mockRunner.On("Run").Return(&exec.Cmd{}, nil)
+	mockRunner.On("Kill").Return(nil)
+	mockRunner.On("Errors").Return(runnerErrorsChan)
+	mockRunner.On("ExitStatus").Return(2)
+
+	// the restart policy is not applied when running once
+	cfg := &Config{Once: true}
+	go func() {
+		runnerErrorsChan <- errors.New("exit status 2")
+	}()
+
+	err := run(cfg, make(chan os.Signal, 2), mockBuilder, mockRunner, &noopWatcher{})
+	exitErr, ok := err.(*ProgramExitError)
+	assert.True(t, ok, "program exit error")
+	assert.Equal(t, 2, exitErr.ExitCode())
+	mockRunner.AssertNumberOfCalls(t, "Run", 1)
+}
+
+func TestGaperServicesScopedRestart(t *testing.T) {
+	newMocks := func() (*testdata.MockBuilder, *testdata.MockRunner, chan error) {
+		mockBuilder := new(testdata.MockBuilder)
+		mockBuilder.On("Build").Return(nil)
+
+		mockRunner := new(testdata.MockRunner)
+		runnerErrorsChan := make(chan error)
+		mockRunner.On("Run").Return(&exec.Cmd{}, nil)
+		mockRunner.On("Errors").Return(runnerErrorsChan)
+		mockRunner.On("Kill").Return(nil)
+		mockRunner.On("Exited").Return(false)
+		mockRunner.On("IsRunning").Return(true)
+		return mockBuilder, mockRunner, runnerErrorsChan
+	}
+
+	apiBuilder, apiRunner, _ := newMocks()
+	workerBuilder, workerRunner, _ := newMocks()
+
+	services := []*service{
+		{
+			name:       "api",
+			builder:    apiBuilder,
+			runner:     apiRunner,
+			watchPaths: map[string]bool{"api": true},
+		},
+		{
+			name:       "worker",
+			builder:    workerBuilder,
+			runner:     workerRunner,
+			watchPaths: map[string]bool{"worker": true},
+		},
+	}
+
+	mockWatcher := new(testdata.MockWacther)
+	watcherErrorsChan := make(chan error)
+	watcherEvetnsChan := make(chan string)
+	mockWatcher.On("Errors").Return(watcherErrorsChan)
+	mockWatcher.On("Events").Return(watcherEvetnsChan)
+
+	chOSSiginal := make(chan os.Signal, 2)
+	go func() {
+		time.Sleep(500 * time.Millisecond)
+		watcherEvetnsChan <- filepath.Join("api", "main.go")
+		time.Sleep(500 * time.Millisecond)
+		chOSSiginal <- syscall.SIGINT
+	}()
+
+	err := runServices(context.Background(), &Config{}, chOSSiginal, services, mockWatcher)
+	assert.NotNil(t, err, "run error")
+	assert.Equal(t, "OS signal: interrupt", err.Error())
+
+	// api was built on start and on the restart, worker only on start
+	apiBuilder.AssertNumberOfCalls(t, "Build", 2)
+	apiRunner.AssertNumberOfCalls(t, "Run", 2)
+	workerBuilder.AssertNumberOfCalls(t, "Build", 1)
+	workerRunner.AssertNumberOfCalls(t, "Run", 1)
+	assert.True(t, services[0].changeRestart)
+	assert.False(t, services[1].changeRestart)
+}
+
+// newRestartTestService creates a service with mocks which build and run successfully
+func newRestartTestService(name string, dependencies ...*service) (*service, *testdata.MockBuilder) {
+	mockBuilder := new(testdata.MockBuilder)
+	mockBuilder.On("Build").Return(nil)
+
+	mockRunner := new(testdata.MockRunner)
+	mockRunner.On("Run").Return(&exec.Cmd{}, nil)
+	mockRunner.On("Kill").Return(nil)
+	mockRunner.On("Exited").Return(false)
+	mockRunner.On("IsRunning").Return(true)
+
+	svc := &service{name: name, builder: mockBuilder, runner: mockRunner, dependencies: dependencies}
+	svc.restartWithDeps = len(dependencies) > 0
+	return svc, mockBuilder
+}
+
+// restartWithDependents restarts the service and then its dependents as they get ready,
+// as the run loop does
+func restartWithDependents(t *testing.T, services []*service, svc *service) {
+	notifier := newReadyNotifier(services)
+	defer notifier.close()
+
+	svc.startCycle(CycleTriggerChange, nil)
+	assert.Nil(t, restartService(services, svc), "restart error")
+
+	for {
+		pending := false
+		for _, s := range services {
+			pending = pending || len(s.waitingFor) > 0
+		}
+
+		if !pending {
+			return
+		}
+
+		select {
+		case ready := <-notifier.ready:
+			assert.Nil(t, restartReadyDependents(services, ready), "restart error")
+		case <-time.After(5 * time.Second):
+			assert.Fail(t, "timeout waiting for the dependents to restart")
+			return
+		}
+	}
+}
+
+func TestGaperServicesRestartDependents(t *testing.T) {
+	// the probe of api never succeeds, as nothing listens on the port
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	probe := "tcp://" + listener.Addr().String()
+	listener.Close() // nolint errcheck
+
+	t.Run("restart with dependencies", func(t *testing.T) {
+		api, apiBuilder := newRestartTestService("api")
+		worker, workerBuilder := newRestartTestService("worker", api)
+		docs, docsBuilder := newRestartTestService("docs", api)
+		docs.restartWithDeps = false
+
+		restartWithDependents(t, []*service{api, worker, docs}, api)
+		apiBuilder.AssertNumberOfCalls(t, "Build", 1)
+		workerBuilder.AssertNumberOfCalls(t, "Build", 1)
+		docsBuilder.AssertNumberOfCalls(t, "Build", 0)
+		assert.True(t, worker.changeRestart)
+		assert.False(t, docs.changeRestart)
+	})
+
+	t.Run("wait ready once", func(t *testing.T) {
+		api, _ := newRestartTestService("api")
+		api.ready = probe
+		api.readyTimeout = 500 * time.Millisecond
+		worker, workerBuilder := newRestartTestService("worker", api)
+		cron, cronBuilder := newRestartTestService("cron", api)
+
+		start := time.Now()
+		restartWithDependents(t, []*service{api, worker, cron}, api)
+		workerBuilder.AssertNumberOfCalls(t, "Build", 1)
+		cronBuilder.AssertNumberOfCalls(t, "Build", 1)
+		assert.True(t, time.Since(start) < time.Second, "readiness waited once for both dependents")
+	})
+
+	t.Run("diamond dependencies", func(t *testing.T) {
+		api, _ := newRestartTestService("api")
+		worker, workerBuilder := newRestartTestService("worker", api)
+		cron, cronBuilder := newRestartTestService("cron", api)
+		reports, reportsBuilder := newRestartTestService("reports", worker, cron)
+
+		restartWithDependents(t, []*service{api, worker, cron, reports}, api)
+		workerBuilder.AssertNumberOfCalls(t, "Build", 1)
+		cronBuilder.AssertNumberOfCalls(t, "Build", 1)
+		reportsBuilder.AssertNumberOfCalls(t, "Build", 1)
+		assert.Equal(t, CycleTriggerDependency, reports.cycle.Trigger)
+	})
+}
+
+func TestGaperServicesSignalWhileWaitingReady(t *testing.T) {
+	// the probe of api succeeds on start, but not after the restart once nothing listens on the port
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	api, _ := newRestartTestService("api")
+	api.ready = "tcp://" + listener.Addr().String()
+	api.readyTimeout = 30 * time.Second
+	api.runner.(*testdata.MockRunner).On("Errors").Return(make(chan error))
+	worker, _ := newRestartTestService("worker", api)
+	worker.runner.(*testdata.MockRunner).On("Errors").Return(make(chan error))
+
+	mockWatcher := new(testdata.MockWacther)
+	watcherEvetnsChan := make(chan string)
+	mockWatcher.On("Errors").Return(make(chan error))
+	mockWatcher.On("Events").Return(watcherEvetnsChan)
+
+	chOSSiginal := make(chan os.Signal, 2)
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		listener.Close() // nolint errcheck
+		watcherEvetnsChan <- "main.go"
+		time.Sleep(300 * time.Millisecond)
+		chOSSiginal <- syscall.SIGINT
+	}()
+
+	start := time.Now()
+	err = runServices(context.Background(), &Config{}, chOSSiginal, []*service{api, worker}, mockWatcher)
+	assert.Equal(t, "OS signal: interrupt", err.Error())
+	assert.True(t, time.Since(start) < 5*time.Second, "signal handled while waiting for the dependency")
+}
+
+func TestGaperServicesRestartCycles(t *testing.T) {
+	api, _ := newRestartTestService("api")
+	worker, _ := newRestartTestService("worker")
+	history := NewHistory(0)
+	api.history, worker.history = history, history
+
+	assert.Nil(t, launchServices([]*service{api, worker}), "launch error")
+	assert.Equal(t, CycleTriggerStart, api.cycle.Trigger)
+	assert.Equal(t, CycleTriggerStart, worker.cycle.Trigger)
+
+	// the restart follows the cycle started with its own trigger
+	_, err := handleProgramExit([]*service{api, worker}, worker, ExitInfo{ExitCode: 1}, false)
+	assert.Nil(t, err, "restart error")
+
+	var triggers []string
+	for _, c := range history.Cycles() {
+		triggers = append(triggers, c.Service+":"+c.Trigger)
+	}
+	assert.Equal(t, []string{"api:" + CycleTriggerStart, "worker:" + CycleTriggerStart, "worker:" + CycleTriggerExit}, triggers)
+}
+
+func TestGaperServicesBuildError(t *testing.T) {
+	mockBuilder := new(testdata.MockBuilder)
+	mockBuilder.On("Build").Return(errors.New("build-error"))
+
+	services := []*service{{name: "api", builder: mockBuilder}}
+
+	chOSSiginal := make(chan os.Signal, 2)
+	err := runServices(context.Background(), &Config{}, chOSSiginal, services, new(testdata.MockWacther))
+	assert.NotNil(t, err, "build error")
+	assert.Equal(t, "build error on api: build-error", err.Error())
+}
+
 func TestGaperRestartExited(t *testing.T) {
 	mockBuilder := new(testdata.MockBuilder)
 	mockBuilder.On("Build").Return(nil)
@@ -203,7 +764,7 @@ func TestGaperRestartExited(t *testing.T) {
 	mockRunner.On("Run").Return(cmd, nil)
 	mockRunner.On("Exited").Return(true)
 
-	err := restart(mockBuilder, mockRunner)
+	err := restart(mockBuilder, mockRunner, newCycle("", CycleTriggerChange, nil))
 	assert.Nil(t, err, "restart error")
 	mockBuilder.AssertExpectations(t)
 	mockRunner.AssertExpectations(t)
@@ -219,7 +780,7 @@ func TestGaperRestartNotExited(t *testing.T) {
 	mockRunner.On("Kill").Return(nil)
 	mockRunner.On("Exited").Return(false)
 
-	err := restart(mockBuilder, mockRunner)
+	err := restart(mockBuilder, mockRunner, newCycle("", CycleTriggerChange, nil))
 	assert.Nil(t, err, "restart error")
 	mockBuilder.AssertExpectations(t)
 	mockRunner.AssertExpectations(t)
@@ -232,7 +793,7 @@ func TestGaperRestartNotExitedKillFail(t *testing.T) {
 	mockRunner.On("Kill").Return(errors.New("kill-error"))
 	mockRunner.On("Exited").Return(false)
 
-	err := restart(mockBuilder, mockRunner)
+	err := restart(mockBuilder, mockRunner, newCycle("", CycleTriggerChange, nil))
 	assert.NotNil(t, err, "restart error")
 	assert.Equal(t, "kill error: kill-error", err.Error())
 	mockBuilder.AssertExpectations(t)
@@ -246,7 +807,7 @@ func TestGaperRestartBuildFail(t *testing.T) {
 	mockRunner := new(testdata.MockRunner)
 	mockRunner.On("Exited").Return(true)
 
-	err := restart(mockBuilder, mockRunner)
+	err := restart(mockBuilder, mockRunner, newCycle("", CycleTriggerChange, nil))
 	assert.Nil(t, err, "restart error")
 	mockBuilder.AssertExpectations(t)
 	mockRunner.AssertExpectations(t)
@@ -261,7 +822,7 @@ func TestGaperRestartRunFail(t *testing.T) {
 	mockRunner.On("Run").Return(cmd, errors.New("run-error"))
 	mockRunner.On("Exited").Return(true)
 
-	err := restart(mockBuilder, mockRunner)
+	err := restart(mockBuilder, mockRunner, newCycle("", CycleTriggerChange, nil))
 	assert.Nil(t, err, "restart error")
 	mockBuilder.AssertExpectations(t)
 	mockRunner.AssertExpectations(t)
@@ -0,0 +1,88 @@
+package gaper
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// gitDirName is the name of the git directory, or file for worktrees and submodules
+const gitDirName = ".git"
+
+// gitIndexLock is the lock file of the git index
+const gitIndexLock = "index.lock"
+
+// files and directories inside the git directory which only exist
+// while a git operation is in progress
+var gitOperationFiles = []string{
+	gitIndexLock,
+	"HEAD.lock",
+	"MERGE_HEAD",
+	"REBASE_HEAD",
+	"CHERRY_PICK_HEAD",
+	"REVERT_HEAD",
+	"rebase-merge",
+	"rebase-apply",
+}
+
+// findGitDir looks for the git directory of the repository containing dir,
+// returning an empty string if dir is not inside a repository
+func findGitDir(dir string) string {
+	for {
+		path := filepath.Join(dir, gitDirName)
+		if info, err := os.Stat(path); err == nil {
+			if info.IsDir() {
+				return path
+			}
+
+			return readGitDirFile(dir, path)
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+
+		dir = parent
+	}
+}
+
+// readGitDirFile resolves the git directory from a ".git" file
+// such as "gitdir: ../.git/worktrees/feature"
+func readGitDirFile(dir string, path string) string {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+
+	content := strings.TrimSpace(string(data))
+	if !strings.HasPrefix(content, "gitdir:") {
+		return ""
+	}
+
+	gitDir := strings.TrimSpace(strings.TrimPrefix(content, "gitdir:"))
+	if !filepath.IsAbs(gitDir) {
+		gitDir = filepath.Join(dir, gitDir)
+	}
+
+	return gitDir
+}
+
+// gitPauseFiles returns the files pausing the watching while a git operation
+// is in progress on the repository containing dir
+func gitPauseFiles(dir string) []string {
+	gitDir := findGitDir(dir)
+	if gitDir == "" {
+		return nil
+	}
+
+	logger.Debug("Pausing the watching during git operations on", gitDir)
+
+	files := make([]string, 0, len(gitOperationFiles))
+	for _, name := range gitOperationFiles {
+		files = append(files, filepath.Join(gitDir, name))
+	}
+
+	return files
+}
@@ -0,0 +1,47 @@
+package gaper
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFindGitDir(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gaper-git")
+	assert.Nil(t, err, "temp dir error")
+	defer os.RemoveAll(dir) // nolint errcheck
+
+	repo := filepath.Join(dir, "repo")
+	nested := filepath.Join(repo, "cmd", "server")
+	assert.Nil(t, os.MkdirAll(filepath.Join(repo, gitDirName), os.ModePerm))
+	assert.Nil(t, os.MkdirAll(nested, os.ModePerm))
+
+	assert.Equal(t, filepath.Join(repo, gitDirName), findGitDir(repo))
+	assert.Equal(t, filepath.Join(repo, gitDirName), findGitDir(nested))
+
+	// worktrees have a .git file pointing to the git directory
+	worktree := filepath.Join(dir, "worktree")
+	assert.Nil(t, os.MkdirAll(worktree, os.ModePerm))
+	content := []byte("gitdir: ../repo/.git/worktrees/feature\n")
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(worktree, gitDirName), content, 0644))
+	assert.Equal(t, filepath.Join(repo, gitDirName, "worktrees", "feature"), findGitDir(worktree))
+}
+
+func TestGitPauseFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gaper-git")
+	assert.Nil(t, err, "temp dir error")
+	defer os.RemoveAll(dir) // nolint errcheck
+
+	gitDir := filepath.Join(dir, gitDirName)
+	assert.Nil(t, os.MkdirAll(gitDir, os.ModePerm))
+
+	files := gitPauseFiles(dir)
+	assert.Len(t, files, len(gitOperationFiles))
+	assert.Contains(t, files, filepath.Join(gitDir, "index.lock"))
+	assert.Contains(t, files, filepath.Join(gitDir, "MERGE_HEAD"))
+	assert.Contains(t, files, filepath.Join(gitDir, "REBASE_HEAD"))
+	assert.Contains(t, files, filepath.Join(gitDir, "rebase-merge"))
+}
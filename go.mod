@@ -3,6 +3,7 @@ module github.com/maxcnunes/gaper
 go 1.13
 
 require (
+	github.com/creack/pty v1.1.13
 	github.com/fatih/color v1.7.0
 	github.com/mattn/go-colorable v0.0.9 // indirect
 	github.com/mattn/go-isatty v0.0.3 // indirect
@@ -11,6 +12,6 @@ require (
 	github.com/stretchr/objx v0.1.1 // indirect
 	github.com/stretchr/testify v1.4.0
 	github.com/urfave/cli/v2 v2.11.1
-	golang.org/x/sys v0.0.0-20180616030259-6c888cc515d3 // indirect
-	gopkg.in/yaml.v2 v2.4.0 // indirect
+	golang.org/x/mod v0.8.0
+	gopkg.in/yaml.v2 v2.4.0
 )
@@ -0,0 +1,192 @@
+package gaper
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"strings"
+	"sync"
+)
+
+// DefaultEscapeKey is the key pressed before a keyboard command
+// while the input is forwarded to the program
+var DefaultEscapeKey = "ctrl-]"
+
+// control keys that can't be used as escape key: the interrupt,
+// the tab, the line breaks and the escape of the terminal sequences
+var reservedEscapeKeys = "cijm["
+
+// maxPendingInput is the size of the input kept while no program is running
+const maxPendingInput = 64 * 1024
+
+// eotKey ends the input of a program attached to a pseudo-terminal
+const eotKey = 0x04
+
+// parseEscapeKey converts an escape key (e.g. "ctrl-]") to the byte read from the terminal
+func parseEscapeKey(key string) (byte, error) {
+	name := strings.ToLower(key)
+	if len(name) == len("ctrl-x") && strings.HasPrefix(name, "ctrl-") {
+		c := name[len(name)-1]
+		valid := (c >= 'a' && c <= 'z') || strings.IndexByte("@[\\]^_", c) >= 0
+		if valid && strings.IndexByte(reservedEscapeKeys, c) < 0 {
+			return c & 0x1f, nil
+		}
+	}
+
+	return 0, fmt.Errorf("invalid escape key \"%s\": it must be a control key (e.g. \"ctrl-]\" or \"ctrl-g\") "+
+		"other than ctrl-c, ctrl-i, ctrl-j, ctrl-m and ctrl-[", key)
+}
+
+// inputForwarder forwards the input read by gaper to the program currently running
+type inputForwarder struct {
+	mu sync.Mutex
+	w  io.Writer
+	// ends the input of the attached program
+	end func()
+	// input read while no program is running, forwarded to the next program started
+	pending []byte
+	// the input is over, so the programs started from now on get no input at all
+	over bool
+}
+
+// attach forwards the input to the program just started, the end function
+// is called once there is no more input
+func (f *inputForwarder) attach(w io.Writer, end func()) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if len(f.pending) > 0 {
+		if _, err := w.Write(f.pending); err != nil {
+			logger.Debug("Error forwarding input to the program:", err)
+		}
+		f.pending = nil
+	}
+
+	if f.over {
+		end()
+		return
+	}
+
+	f.w, f.end = w, end
+}
+
+// detach stops forwarding the input to the program, unless it has already been replaced
+func (f *inputForwarder) detach(w io.Writer) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.w == w {
+		f.w, f.end = nil, nil
+	}
+}
+
+// Write forwards the input to the attached program, while no program runs the
+// input is kept for the next program started, dropping it beyond maxPendingInput
+func (f *inputForwarder) Write(data []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.w == nil {
+		if len(f.pending)+len(data) <= maxPendingInput {
+			f.pending = append(f.pending, data...)
+		}
+		return len(data), nil
+	}
+
+	if _, err := f.w.Write(data); err != nil {
+		logger.Debug("Error forwarding input to the program:", err)
+	}
+
+	return len(data), nil
+}
+
+// close ends the input of the attached program and of the ones started afterwards
+func (f *inputForwarder) close() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.over = true
+	if f.end != nil {
+		f.end()
+	}
+}
+
+// startInput forwards stdin to the programs, reading a keyboard command from the key pressed
+// after the escape key, unless it is empty. With raw set, which is used when the programs run in a
+// pseudo-terminal, the terminal is changed to forward every key as soon as it is pressed. The
+// returned function restores the previous terminal settings.
+func startInput(input *inputForwarder, escapeKey string, raw bool, commands chan<- Command) (func(), error) {
+	var escape byte
+	if escapeKey != "" {
+		var err error
+		if escape, err = parseEscapeKey(escapeKey); err != nil {
+			return nil, err
+		}
+	}
+
+	restore := func() {}
+	if raw && runtime.GOOS != OSWindows && isTerminal(os.Stdin) {
+		var err error
+		// output processing is kept, so the messages from gaper still start on a new line
+		if restore, err = setTerminal("raw", "-echo", "opost"); err != nil {
+			return nil, err
+		}
+	}
+
+	if escape != 0 {
+		logger.Info("Keyboard commands after pressing " + strings.ToLower(escapeKey) +
+			": (r)estart, (s)top/start, (c)lear, (v)erbose, (q)uit")
+	}
+
+	go readInput(os.Stdin, input, escape, commands)
+	return restore, nil
+}
+
+// readInput forwards the input until the reader is over. The key pressed after the escape key
+// is read as a keyboard command instead, pressing the escape key twice forwards it as it is.
+func readInput(in io.Reader, input *inputForwarder, escape byte, commands chan<- Command) {
+	buf := make([]byte, 1024)
+	escaped, afterCommand := false, false
+	for {
+		n, err := in.Read(buf)
+
+		forward := make([]byte, 0, n)
+		for _, b := range buf[:n] {
+			// the line break typed after a command on a line buffered terminal is not forwarded
+			if afterCommand {
+				afterCommand = false
+				if b == '\n' {
+					continue
+				}
+			}
+
+			switch {
+			case escaped && b != escape:
+				escaped = false
+				input.Write(forward) // nolint errcheck
+				forward = forward[:0]
+
+				if cmd, ok := keyCommands[b]; ok {
+					commands <- cmd
+				}
+				afterCommand = true
+			case escape != 0 && b == escape && !escaped:
+				escaped = true
+			default:
+				escaped = false
+				forward = append(forward, b)
+			}
+		}
+
+		if len(forward) > 0 {
+			input.Write(forward) // nolint errcheck
+		}
+
+		if err != nil {
+			logger.Debug("Stopped forwarding input:", err)
+			input.close()
+			return
+		}
+	}
+}
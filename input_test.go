@@ -0,0 +1,131 @@
+package gaper
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseEscapeKey(t *testing.T) {
+	testCases := []struct {
+		key      string
+		expected byte
+		valid    bool
+	}{
+		{key: "ctrl-]", expected: 0x1d, valid: true},
+		{key: "ctrl-g", expected: 0x07, valid: true},
+		{key: "Ctrl-A", expected: 0x01, valid: true},
+		{key: "ctrl-c"},
+		{key: "ctrl-["},
+		{key: "ctrl-m"},
+		{key: "ctrl-1"},
+		{key: "g"},
+		{key: "ctrl-gg"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.key, func(t *testing.T) {
+			escape, err := parseEscapeKey(tc.key)
+			if !tc.valid {
+				assert.NotNil(t, err, "invalid escape key")
+				return
+			}
+
+			assert.Nil(t, err, "valid escape key")
+			assert.Equal(t, tc.expected, escape)
+		})
+	}
+}
+
+func TestInputForwarder(t *testing.T) {
+	input := &inputForwarder{}
+
+	// kept until a program is attached
+	input.Write([]byte("before ")) // nolint errcheck
+
+	first := bytes.NewBufferString("")
+	input.attach(first, func() {})
+	input.Write([]byte("first")) // nolint errcheck
+	assert.Equal(t, "before first", first.String())
+
+	// a program replaced by another is not detached
+	second := bytes.NewBufferString("")
+	ended := false
+	input.attach(second, func() { ended = true })
+	input.detach(first)
+	input.Write([]byte("second")) // nolint errcheck
+	assert.Equal(t, "second", second.String())
+
+	input.close()
+	assert.True(t, ended, "input ended")
+
+	// programs started once the input is over get no input
+	input.detach(second)
+	endedThird := false
+	input.attach(bytes.NewBufferString(""), func() { endedThird = true })
+	assert.True(t, endedThird, "input ended right away")
+}
+
+func TestReadInput(t *testing.T) {
+	testCases := []struct {
+		name     string
+		in       string
+		escape   byte
+		expected string
+		commands []Command
+	}{
+		{
+			name:     "without escape key",
+			in:       "r\x1dq\n",
+			expected: "r\x1dq\n",
+		},
+		{
+			name:     "commands after the escape key",
+			in:       "ab\x1dr\x1dqcd",
+			escape:   0x1d,
+			expected: "abcd",
+			commands: []Command{CommandRestart, CommandQuit},
+		},
+		{
+			name:     "line break after a command",
+			in:       "a\n\x1dv\nb\n",
+			escape:   0x1d,
+			expected: "a\nb\n",
+			commands: []Command{CommandVerbose},
+		},
+		{
+			name:     "escape key pressed twice",
+			in:       "a\x1d\x1db",
+			escape:   0x1d,
+			expected: "a\x1db",
+		},
+		{
+			name:     "unknown command",
+			in:       "a\x1dxb",
+			escape:   0x1d,
+			expected: "ab",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			out := bytes.NewBufferString("")
+			input := &inputForwarder{}
+			input.attach(out, func() {})
+
+			commands := make(chan Command, 10)
+			readInput(strings.NewReader(tc.in), input, tc.escape, commands)
+			close(commands)
+
+			var received []Command
+			for cmd := range commands {
+				received = append(received, cmd)
+			}
+
+			assert.Equal(t, tc.expected, out.String())
+			assert.Equal(t, tc.commands, received)
+		})
+	}
+}
@@ -0,0 +1,119 @@
+package gaper
+
+import (
+	"io"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// Command is an action requested to the run loop while the programs are supervised
+type Command string
+
+// commands supported by the run loop
+const (
+	// rebuilds and restarts all programs
+	CommandRestart Command = "restart"
+	// stops the programs if they are running, otherwise starts them
+	CommandToggle Command = "toggle"
+	// stops the programs until they are started again
+	CommandStop Command = "stop"
+	// starts the programs stopped by a command
+	CommandStart Command = "start"
+	// stops reacting to file changes until resumed
+	CommandPause Command = "pause"
+	// reacts to the changes made while paused and to the next ones
+	CommandResume Command = "resume"
+	// resumes the watching if it is paused, otherwise pauses it
+	CommandTogglePause Command = "toggle-pause"
+	// clears the terminal screen
+	CommandClear Command = "clear"
+	// toggles the verbose messages from gaper
+	CommandVerbose Command = "verbose"
+	// stops the programs and gaper
+	CommandQuit Command = "quit"
+)
+
+// keyCommands maps the keys read from the terminal to their commands
+var keyCommands = map[byte]Command{
+	'r': CommandRestart,
+	's': CommandToggle,
+	'c': CommandClear,
+	'v': CommandVerbose,
+	'q': CommandQuit,
+}
+
+// clearScreen is the escape sequence moving the cursor to the top and clearing the terminal
+const clearScreen = "\033[H\033[2J"
+
+// isTerminal checks if the file is a terminal
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	if err != nil {
+		return false
+	}
+
+	return info.Mode()&os.ModeCharDevice != 0
+}
+
+// startKeyboard reads single keystrokes from stdin sending their commands. The terminal
+// is changed to not wait for a new line and not echo the keys, the returned function
+// restores the previous terminal settings.
+func startKeyboard(commands chan<- Command) (func(), error) {
+	if runtime.GOOS == OSWindows || !isTerminal(os.Stdin) {
+		logger.Debug("Keyboard commands are only supported on Unix terminals")
+		return func() {}, nil
+	}
+
+	restore, err := setTerminal("-icanon", "-echo", "min", "1")
+	if err != nil {
+		return nil, err
+	}
+
+	logger.Info("Keyboard commands: (r)estart, (s)top/start, (c)lear, (v)erbose, (q)uit")
+	go readKeys(os.Stdin, commands)
+	return restore, nil
+}
+
+// readKeys sends the commands of the keys read until the reader is over
+func readKeys(in io.Reader, commands chan<- Command) {
+	buf := make([]byte, 1)
+	for {
+		if _, err := in.Read(buf); err != nil {
+			logger.Debug("Stopped reading keyboard commands:", err)
+			return
+		}
+
+		if cmd, ok := keyCommands[buf[0]]; ok {
+			commands <- cmd
+		}
+	}
+}
+
+// setTerminal changes the settings of the terminal attached to stdin,
+// the returned function restores the previous settings
+func setTerminal(settings ...string) (func(), error) {
+	state, err := stty("-g")
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := stty(settings...); err != nil {
+		return nil, err
+	}
+
+	return func() {
+		if _, err := stty(strings.TrimSpace(state)); err != nil {
+			logger.Debug("Error restoring terminal settings:", err)
+		}
+	}, nil
+}
+
+// stty changes or reads the settings of the terminal attached to stdin
+func stty(args ...string) (string, error) {
+	cmd := exec.Command("stty", args...) // nolint gas
+	cmd.Stdin = os.Stdin
+	out, err := cmd.Output()
+	return string(out), err
+}
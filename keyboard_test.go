@@ -0,0 +1,22 @@
+package gaper
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReadKeys(t *testing.T) {
+	commands := make(chan Command, 10)
+	readKeys(strings.NewReader("rxscvq\n"), commands)
+	close(commands)
+
+	var result []Command
+	for cmd := range commands {
+		result = append(result, cmd)
+	}
+
+	expected := []Command{CommandRestart, CommandToggle, CommandClear, CommandVerbose, CommandQuit}
+	assert.Equal(t, expected, result)
+}
@@ -0,0 +1,107 @@
+package gaper
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// DefaultLogFileMaxSize is the default size in MB a log file can reach before being rotated
+var DefaultLogFileMaxSize = 10
+
+// DefaultLogFileMaxBackups is the default number of rotated log files kept
+var DefaultLogFileMaxBackups = 3
+
+// rotatingFile is a file opened in append mode which is rotated once it reaches
+// the max size. Rotated files are renamed with a numeric suffix (e.g. gaper.log.1),
+// the higher the number the older the file.
+type rotatingFile struct {
+	mu         sync.Mutex
+	path       string
+	maxSize    int64
+	maxBackups int
+	file       *os.File
+	size       int64
+}
+
+// openRotatingFile opens a rotating file, a maxSize of 0 disables the rotation
+func openRotatingFile(path string, maxSize int64, maxBackups int) (*rotatingFile, error) {
+	f := &rotatingFile{path: path, maxSize: maxSize, maxBackups: maxBackups}
+	if err := f.open(); err != nil {
+		return nil, err
+	}
+
+	return f, nil
+}
+
+// Write appends data to the file, rotating it before if it would exceed the max size
+func (f *rotatingFile) Write(data []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.maxSize > 0 && f.size > 0 && f.size+int64(len(data)) > f.maxSize {
+		if err := f.rotate(); err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := f.file.Write(data)
+	f.size += int64(n)
+	return n, err
+}
+
+// Close closes the file
+func (f *rotatingFile) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.file.Close()
+}
+
+func (f *rotatingFile) open() error {
+	if err := os.MkdirAll(filepath.Dir(f.path), os.ModePerm); err != nil {
+		return err
+	}
+
+	file, err := os.OpenFile(f.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close() // nolint errcheck
+		return err
+	}
+
+	f.file = file
+	f.size = info.Size()
+	return nil
+}
+
+// rotate shifts the backups by one, dropping the oldest one, and starts a new file
+func (f *rotatingFile) rotate() error {
+	if err := f.file.Close(); err != nil {
+		return err
+	}
+
+	if f.maxBackups > 0 {
+		os.Remove(backupPath(f.path, f.maxBackups)) // nolint errcheck
+		for i := f.maxBackups - 1; i > 0; i-- {
+			os.Rename(backupPath(f.path, i), backupPath(f.path, i+1)) // nolint errcheck
+		}
+
+		if err := os.Rename(f.path, backupPath(f.path, 1)); err != nil {
+			return err
+		}
+	} else if err := os.Remove(f.path); err != nil {
+		return err
+	}
+
+	return f.open()
+}
+
+func backupPath(path string, index int) string {
+	return fmt.Sprintf("%s.%d", path, index)
+}
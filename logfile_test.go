@@ -0,0 +1,63 @@
+package gaper
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLogFileRotation(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gaper-logfile")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir) // nolint errcheck
+
+	path := filepath.Join(dir, "gaper.log")
+	f, err := openRotatingFile(path, 10, 2)
+	assert.Nil(t, err, "open error")
+
+	for _, line := range []string{"first\n", "second\n", "third\n", "fourth\n"} {
+		_, err = f.Write([]byte(line))
+		assert.Nil(t, err, "write error")
+	}
+	assert.Nil(t, f.Close())
+
+	readFile := func(p string) string {
+		data, err := ioutil.ReadFile(p)
+		assert.Nil(t, err, "read error")
+		return string(data)
+	}
+
+	assert.Equal(t, "fourth\n", readFile(path))
+	assert.Equal(t, "third\n", readFile(backupPath(path, 1)))
+	assert.Equal(t, "second\n", readFile(backupPath(path, 2)))
+	_, err = os.Stat(backupPath(path, 3))
+	assert.True(t, os.IsNotExist(err), "oldest backup not removed")
+}
+
+func TestLogFileAppend(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gaper-logfile")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir) // nolint errcheck
+
+	path := filepath.Join(dir, "gaper.log")
+	if err := ioutil.WriteFile(path, []byte("existing\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := openRotatingFile(path, 0, 0)
+	assert.Nil(t, err, "open error")
+	_, err = f.Write([]byte("new\n"))
+	assert.Nil(t, err, "write error")
+	assert.Nil(t, f.Close())
+
+	data, err := ioutil.ReadFile(path)
+	assert.Nil(t, err, "read error")
+	assert.Equal(t, "existing\nnew\n", string(data))
+}
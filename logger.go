@@ -1,12 +1,40 @@
 package gaper
 
 import (
+	"encoding/json"
+	"fmt"
+	"io"
 	"log"
 	"os"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/fatih/color"
 )
 
+// log formats supported by the logger
+const (
+	LogFormatText = "text"
+	LogFormatJSON = "json"
+)
+
+// log levels supported by the logger
+const (
+	LogLevelDebug = "debug"
+	LogLevelInfo  = "info"
+	LogLevelWarn  = "warn"
+	LogLevelError = "error"
+)
+
+// severity of each log level, messages below the logger level are discarded
+var logLevels = map[string]int{
+	LogLevelDebug: 0,
+	LogLevelInfo:  1,
+	LogLevelWarn:  2,
+	LogLevelError: 3,
+}
+
 // logger use by the whole package
 var logger = newLogger("gaper")
 
@@ -15,55 +43,353 @@ func Logger() *LoggerEntity {
 	return logger
 }
 
+// LogField is a structured value attached to a log message
+type LogField struct {
+	Key   string
+	Value interface{}
+}
+
+// Field creates a structured value for a log message
+func Field(key string, value interface{}) LogField {
+	return LogField{Key: key, Value: value}
+}
+
+// logHandler writes the log messages in a specific format
+type logHandler interface {
+	Handle(level string, msg string, fields []LogField)
+}
+
 // LoggerEntity used by gaper
 type LoggerEntity struct {
-	verbose  bool
-	logDebug *log.Logger
-	logInfo  *log.Logger
-	logError *log.Logger
+	name string
+	// the level is changed at runtime (e.g. by the verbose command)
+	// while the messages are logged from other goroutines
+	mu      sync.RWMutex
+	level   string
+	format  string
+	stdout  io.Writer
+	stderr  io.Writer
+	file    io.WriteCloser
+	handler logHandler
 }
 
 // newLogger creates a new logger
-func newLogger(prefix string) *LoggerEntity {
-	prefix = "[" + prefix + "] "
-	return &LoggerEntity{
-		verbose:  false,
-		logDebug: log.New(os.Stdout, prefix, 0),
-		logInfo:  log.New(os.Stdout, color.CyanString(prefix), 0),
-		logError: log.New(os.Stdout, color.RedString(prefix), 0),
+func newLogger(name string) *LoggerEntity {
+	l := &LoggerEntity{
+		name:   name,
+		level:  LogLevelInfo,
+		format: LogFormatText,
+		stdout: os.Stdout,
+		stderr: os.Stderr,
 	}
+	l.resetHandler()
+	return l
 }
 
 // Verbose toggle this logger verbosity
 func (l *LoggerEntity) Verbose(verbose bool) {
-	l.verbose = verbose
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if verbose {
+		l.level = LogLevelDebug
+	} else if l.level == LogLevelDebug {
+		l.level = LogLevelInfo
+	}
+}
+
+// IsVerbose checks if debug messages are logged
+func (l *LoggerEntity) IsVerbose() bool {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.level == LogLevelDebug
+}
+
+// Quiet only logs error messages when enabled
+func (l *LoggerEntity) Quiet(quiet bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if quiet {
+		l.level = LogLevelError
+	} else if l.level == LogLevelError {
+		l.level = LogLevelInfo
+	}
+}
+
+// SetLevel changes the minimum level of the logged messages
+func (l *LoggerEntity) SetLevel(level string) error {
+	if _, ok := logLevels[level]; !ok {
+		return fmt.Errorf("invalid log level \"%s\", supported levels: %s, %s, %s, %s",
+			level, LogLevelDebug, LogLevelInfo, LogLevelWarn, LogLevelError)
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.level = level
+	return nil
+}
+
+// SetFormat changes the format used to write the log messages
+func (l *LoggerEntity) SetFormat(format string) error {
+	switch format {
+	case "":
+		l.format = LogFormatText
+	case LogFormatText, LogFormatJSON:
+		l.format = format
+	default:
+		return fmt.Errorf("invalid log format \"%s\", supported formats: %s, %s", format, LogFormatText, LogFormatJSON)
+	}
+
+	l.resetHandler()
+	return nil
+}
+
+// SetFile appends the log messages to a file besides the terminal. The file is rotated
+// once it reaches maxSize bytes, keeping up to maxBackups old files.
+func (l *LoggerEntity) SetFile(path string, maxSize int64, maxBackups int) error {
+	file, err := openRotatingFile(path, maxSize, maxBackups)
+	if err != nil {
+		return fmt.Errorf("couldn't open log file: %v", err)
+	}
+
+	if l.file != nil {
+		l.file.Close() // nolint errcheck
+	}
+
+	l.file = file
+	l.resetHandler()
+	return nil
+}
+
+// Close releases the log file if there is one
+func (l *LoggerEntity) Close() error {
+	if l.file == nil {
+		return nil
+	}
+
+	err := l.file.Close()
+	l.file = nil
+	l.resetHandler()
+	return err
+}
+
+// resetHandler rebuilds the handler with the current settings
+func (l *LoggerEntity) resetHandler() {
+	handler := newLogHandler(l.format, l.stdout, l.stderr, l.name, true)
+	if l.file == nil {
+		l.handler = handler
+		return
+	}
+
+	l.handler = multiLogHandler{handler, newLogHandler(l.format, l.file, l.file, l.name, false)}
+}
+
+// log writes the message if its level is enabled
+func (l *LoggerEntity) log(level string, msg string, fields []LogField) {
+	l.mu.RLock()
+	enabled := logLevels[level] >= logLevels[l.level]
+	l.mu.RUnlock()
+
+	if !enabled {
+		return
+	}
+
+	l.handler.Handle(level, msg, fields)
 }
 
 // Debug logs a debug message
 func (l *LoggerEntity) Debug(v ...interface{}) {
-	if l.verbose {
-		l.logDebug.Println(v...)
-	}
+	l.log(LogLevelDebug, sprintln(v...), nil)
 }
 
 // Debugf logs a debug message with format
 func (l *LoggerEntity) Debugf(format string, v ...interface{}) {
-	if l.verbose {
-		l.logDebug.Printf(format, v...)
-	}
+	l.log(LogLevelDebug, fmt.Sprintf(format, v...), nil)
+}
+
+// DebugWith logs a debug message with structured fields
+func (l *LoggerEntity) DebugWith(msg string, fields ...LogField) {
+	l.log(LogLevelDebug, msg, fields)
 }
 
 // Info logs a info message
 func (l *LoggerEntity) Info(v ...interface{}) {
-	l.logInfo.Println(v...)
+	l.log(LogLevelInfo, sprintln(v...), nil)
+}
+
+// InfoWith logs a info message with structured fields
+func (l *LoggerEntity) InfoWith(msg string, fields ...LogField) {
+	l.log(LogLevelInfo, msg, fields)
+}
+
+// Warn logs a warning message
+func (l *LoggerEntity) Warn(v ...interface{}) {
+	l.log(LogLevelWarn, sprintln(v...), nil)
+}
+
+// Warnf logs a warning message with format
+func (l *LoggerEntity) Warnf(format string, v ...interface{}) {
+	l.log(LogLevelWarn, fmt.Sprintf(format, v...), nil)
+}
+
+// WarnWith logs a warning message with structured fields
+func (l *LoggerEntity) WarnWith(msg string, fields ...LogField) {
+	l.log(LogLevelWarn, msg, fields)
 }
 
 // Error logs an error message
 func (l *LoggerEntity) Error(v ...interface{}) {
-	l.logError.Println(v...)
+	l.log(LogLevelError, sprintln(v...), nil)
 }
 
 // Errorf logs and error message with format
 func (l *LoggerEntity) Errorf(format string, v ...interface{}) {
-	l.logError.Printf(format, v...)
+	l.log(LogLevelError, fmt.Sprintf(format, v...), nil)
+}
+
+// ErrorWith logs an error message with structured fields
+func (l *LoggerEntity) ErrorWith(msg string, fields ...LogField) {
+	l.log(LogLevelError, msg, fields)
+}
+
+// sprintln formats the values the same way as log.Println without the new line
+func sprintln(v ...interface{}) string {
+	return strings.TrimSuffix(fmt.Sprintln(v...), "\n")
+}
+
+// newLogHandler creates a handler for the given format writing
+// error messages to errOut and any other message to out
+func newLogHandler(format string, out io.Writer, errOut io.Writer, name string, colored bool) logHandler {
+	if format == LogFormatJSON {
+		return newJSONLogHandler(out, errOut, name)
+	}
+
+	return newTextLogHandler(out, errOut, name, colored)
+}
+
+// multiLogHandler writes the log messages to multiple handlers
+type multiLogHandler []logHandler
+
+// Handle writes the message to all handlers
+func (m multiLogHandler) Handle(level string, msg string, fields []LogField) {
+	for _, h := range m {
+		h.Handle(level, msg, fields)
+	}
+}
+
+// textLogHandler writes the log messages as colored plain text
+type textLogHandler struct {
+	logDebug *log.Logger
+	logInfo  *log.Logger
+	logWarn  *log.Logger
+	logError *log.Logger
+}
+
+func newTextLogHandler(out io.Writer, errOut io.Writer, name string, colored bool) *textLogHandler {
+	prefix := "[" + name + "] "
+	infoPrefix, warnPrefix, errorPrefix := prefix, prefix, prefix
+	if colored {
+		infoPrefix = color.CyanString(prefix)
+		warnPrefix = color.YellowString(prefix)
+		errorPrefix = color.RedString(prefix)
+	}
+
+	return &textLogHandler{
+		logDebug: log.New(out, prefix, 0),
+		logInfo:  log.New(out, infoPrefix, 0),
+		logWarn:  log.New(out, warnPrefix, 0),
+		logError: log.New(errOut, errorPrefix, 0),
+	}
+}
+
+// Handle writes the message followed by the fields in the "key=value" format
+func (h *textLogHandler) Handle(level string, msg string, fields []LogField) {
+	var b strings.Builder
+	b.WriteString(msg)
+	for _, f := range fields {
+		fmt.Fprintf(&b, " %s=%v", f.Key, f.Value)
+	}
+
+	switch level {
+	case LogLevelDebug:
+		h.logDebug.Println(b.String())
+	case LogLevelWarn:
+		h.logWarn.Println(b.String())
+	case LogLevelError:
+		h.logError.Println(b.String())
+	default:
+		h.logInfo.Println(b.String())
+	}
+}
+
+// jsonLogHandler writes the log messages as one JSON object per line
+type jsonLogHandler struct {
+	mu     sync.Mutex
+	out    io.Writer
+	errOut io.Writer
+	name   string
+}
+
+func newJSONLogHandler(out io.Writer, errOut io.Writer, name string) *jsonLogHandler {
+	return &jsonLogHandler{out: out, errOut: errOut, name: name}
+}
+
+// Handle writes the message with the level, time and fields as a JSON object
+func (h *jsonLogHandler) Handle(level string, msg string, fields []LogField) {
+	entry := map[string]interface{}{
+		"time":   time.Now().Format(time.RFC3339Nano),
+		"level":  level,
+		"logger": h.name,
+		"msg":    msg,
+	}
+
+	for _, f := range fields {
+		entry[jsonFieldKey(f.Key)] = jsonValue(f.Value)
+	}
+
+	data, err := json.Marshal(entry)
+	if err != nil {
+		data, _ = json.Marshal(map[string]interface{}{ // nolint errcheck
+			"time":  entry["time"],
+			"level": LogLevelError,
+			"msg":   fmt.Sprintf("couldn't encode log message \"%s\": %v", msg, err),
+		})
+	}
+
+	w := h.out
+	if level == LogLevelError {
+		w = h.errOut
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	w.Write(append(data, '\n')) // nolint errcheck
+}
+
+// jsonReservedKeys are the keys set on every JSON message
+var jsonReservedKeys = map[string]bool{"time": true, "level": true, "logger": true, "msg": true}
+
+// jsonFieldKey prefixes the keys of the fields colliding with the keys
+// set on every JSON message (e.g. "fields.msg"), so they don't replace them
+func jsonFieldKey(key string) string {
+	if jsonReservedKeys[key] {
+		return "fields." + key
+	}
+
+	return key
+}
+
+// jsonValue converts values which would lose information on being encoded to JSON
+func jsonValue(v interface{}) interface{} {
+	switch value := v.(type) {
+	case error:
+		return value.Error()
+	case time.Duration:
+		return value.String()
+	case fmt.Stringer:
+		return value.String()
+	}
+
+	return v
 }
@@ -1,20 +1,102 @@
 package gaper
 
 import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
 
 func TestLoggerDefault(t *testing.T) {
 	l := newLogger("gaper-test")
-	assert.Equal(t, l.verbose, false)
+	assert.Equal(t, l.IsVerbose(), false)
+	assert.Equal(t, l.level, LogLevelInfo)
 }
 
 func TestLoggerEnableVerbose(t *testing.T) {
 	l := newLogger("gaper-test")
 	l.Verbose(true)
-	assert.Equal(t, l.verbose, true)
+	assert.Equal(t, l.IsVerbose(), true)
+	l.Verbose(false)
+	assert.Equal(t, l.IsVerbose(), false)
+}
+
+func TestLoggerToggleVerboseWhileLogging(t *testing.T) {
+	l := newLogger("gaper-test")
+	l.handler = newTextLogHandler(ioutil.Discard, ioutil.Discard, "gaper-test", false)
+
+	// run with -race to check the level is changed safely
+	done := make(chan bool)
+	go func() {
+		for i := 0; i < 100; i++ {
+			l.Debug("debug")
+		}
+		close(done)
+	}()
+
+	for i := 0; i < 100; i++ {
+		l.Verbose(!l.IsVerbose())
+	}
+	<-done
+}
+
+func TestLoggerLevels(t *testing.T) {
+	out := bytes.NewBufferString("")
+	errOut := bytes.NewBufferString("")
+	l := newLogger("gaper-test")
+	l.stdout, l.stderr = out, errOut
+	l.resetHandler()
+
+	logAll := func() {
+		l.Debug("debug")
+		l.Info("info")
+		l.Warn("warn")
+		l.Error("error")
+	}
+
+	assert.Nil(t, l.SetLevel(LogLevelWarn))
+	logAll()
+	assert.Equal(t, "[gaper-test] warn\n", out.String())
+	assert.Equal(t, "[gaper-test] error\n", errOut.String())
+
+	out.Reset()
+	errOut.Reset()
+	l.Quiet(true)
+	logAll()
+	assert.Equal(t, "", out.String())
+	assert.Equal(t, "[gaper-test] error\n", errOut.String())
+
+	err := l.SetLevel("trace")
+	assert.NotNil(t, err, "level error")
+	assert.Equal(t, "invalid log level \"trace\", supported levels: debug, info, warn, error", err.Error())
+}
+
+func TestLoggerFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gaper-logger")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir) // nolint errcheck
+
+	l := newLogger("gaper-test")
+	l.stdout, l.stderr = ioutil.Discard, ioutil.Discard
+	path := filepath.Join(dir, "logs", "gaper.log")
+
+	assert.Nil(t, l.SetFile(path, 0, 0))
+	l.Info("info")
+	l.ErrorWith("error", Field("exit_code", 1))
+	assert.Nil(t, l.Close())
+
+	data, err := ioutil.ReadFile(path)
+	assert.Nil(t, err, "read error")
+	assert.Equal(t, "[gaper-test] info\n[gaper-test] error exit_code=1\n", string(data))
 }
 
 func TestLoggerRunAllLogsWithoutVerbose(t *testing.T) {
@@ -23,6 +105,8 @@ func TestLoggerRunAllLogsWithoutVerbose(t *testing.T) {
 	l.Debug("debug")
 	l.Debugf("%s", "debug")
 	l.Info("info")
+	l.Warn("warn")
+	l.Warnf("%s", "warn")
 	l.Error("error")
 	l.Errorf("%s", "error")
 }
@@ -34,6 +118,82 @@ func TestLoggerRunAllLogsWithVerbose(t *testing.T) {
 	l.Debug("debug")
 	l.Debugf("%s", "debug")
 	l.Info("info")
+	l.Warn("warn")
+	l.Warnf("%s", "warn")
 	l.Error("error")
 	l.Errorf("%s", "error")
 }
+
+func TestLoggerSetFormat(t *testing.T) {
+	l := newLogger("gaper-test")
+
+	assert.Nil(t, l.SetFormat(LogFormatJSON))
+	assert.IsType(t, &jsonLogHandler{}, l.handler)
+
+	assert.Nil(t, l.SetFormat(LogFormatText))
+	assert.IsType(t, &textLogHandler{}, l.handler)
+
+	err := l.SetFormat("xml")
+	assert.NotNil(t, err, "format error")
+	assert.Equal(t, "invalid log format \"xml\", supported formats: text, json", err.Error())
+}
+
+func TestLoggerTextFields(t *testing.T) {
+	buf := bytes.NewBufferString("")
+	l := newLogger("gaper-test")
+	l.handler = newTextLogHandler(buf, buf, "gaper-test", false)
+	l.Verbose(true)
+
+	l.DebugWith("Started program", Field("pid", 42))
+	l.Debug("debug", 1)
+	assert.Equal(t, "[gaper-test] Started program pid=42\n[gaper-test] debug 1\n", buf.String())
+}
+
+func TestLoggerJSONFormat(t *testing.T) {
+	buf := bytes.NewBufferString("")
+	l := newLogger("gaper-test")
+	l.handler = newJSONLogHandler(buf, buf, "gaper-test")
+
+	l.DebugWith("not logged without verbose")
+	l.InfoWith("Built program", Field("duration", 1500*time.Millisecond), Field("files", []string{"main.go"}))
+	l.Errorf("Error %s", "building")
+	l.ErrorWith("Detected exit", Field("exit_code", 2), Field("error", errors.New("exit status 2")))
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	assert.Len(t, lines, 3)
+
+	var entry map[string]interface{}
+	assert.Nil(t, json.Unmarshal([]byte(lines[0]), &entry))
+	assert.Equal(t, "info", entry["level"])
+	assert.Equal(t, "gaper-test", entry["logger"])
+	assert.Equal(t, "Built program", entry["msg"])
+	assert.Equal(t, "1.5s", entry["duration"])
+	assert.Equal(t, []interface{}{"main.go"}, entry["files"])
+	assert.NotEmpty(t, entry["time"])
+
+	assert.Nil(t, json.Unmarshal([]byte(lines[1]), &entry))
+	assert.Equal(t, "error", entry["level"])
+	assert.Equal(t, "Error building", entry["msg"])
+
+	entry = nil
+	assert.Nil(t, json.Unmarshal([]byte(lines[2]), &entry))
+	assert.Equal(t, float64(2), entry["exit_code"])
+	assert.Equal(t, "exit status 2", entry["error"])
+}
+
+func TestLoggerJSONReservedFields(t *testing.T) {
+	buf := bytes.NewBufferString("")
+	l := newLogger("gaper-test")
+	l.handler = newJSONLogHandler(buf, buf, "gaper-test")
+
+	l.InfoWith("Built program", Field("msg", "field"), Field("level", "field"), Field("logger", "field"))
+
+	var entry map[string]interface{}
+	assert.Nil(t, json.Unmarshal(buf.Bytes(), &entry))
+	assert.Equal(t, "Built program", entry["msg"])
+	assert.Equal(t, "info", entry["level"])
+	assert.Equal(t, "gaper-test", entry["logger"])
+	assert.Equal(t, "field", entry["fields.msg"])
+	assert.Equal(t, "field", entry["fields.level"])
+	assert.Equal(t, "field", entry["fields.logger"])
+}
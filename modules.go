@@ -0,0 +1,207 @@
+package gaper
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"golang.org/x/mod/modfile"
+)
+
+// module files used to resolve local dependencies
+const (
+	goModFile  = "go.mod"
+	goWorkFile = "go.work"
+)
+
+// localModuleDirs resolves the absolute paths of local modules the program in
+// buildPath depends on. It includes directories from replace directives pointing
+// to the file system (in both go.mod and go.work) and the go.work used modules.
+// The main module directory is not included since it is already watched.
+// Workspace modules are only included if workspace is true.
+func localModuleDirs(buildPath string, workspace bool) ([]string, error) {
+	absBuildPath, err := filepath.Abs(buildPath)
+	if err != nil {
+		return nil, err
+	}
+
+	var dirs []string
+	mainModDir := ""
+
+	if modPath := findFileUp(absBuildPath, goModFile); modPath != "" {
+		mainModDir = filepath.Dir(modPath)
+
+		replaceDirs, err := parseGoModReplaceDirs(modPath)
+		if err != nil {
+			return nil, err
+		}
+		dirs = append(dirs, replaceDirs...)
+	}
+
+	if workPath := findGoWork(absBuildPath); workspace && workPath != "" {
+		workDirs, err := parseGoWorkDirs(workPath)
+		if err != nil {
+			return nil, err
+		}
+		dirs = append(dirs, workDirs...)
+	}
+
+	var result []string
+	added := map[string]bool{}
+	for _, dir := range dirs {
+		if dir == mainModDir || added[dir] {
+			continue
+		}
+
+		added[dir] = true
+		result = append(result, dir)
+	}
+
+	return result, nil
+}
+
+// parseGoModReplaceDirs returns the local directories used by replace directives in a go.mod file
+func parseGoModReplaceDirs(modPath string) ([]string, error) {
+	data, err := ioutil.ReadFile(modPath)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := modfile.Parse(modPath, data, nil)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't parse \"%s\": %v", modPath, err)
+	}
+
+	baseDir := filepath.Dir(modPath)
+
+	var dirs []string
+	for _, r := range f.Replace {
+		if dir, ok := localReplaceDir(baseDir, r); ok {
+			dirs = append(dirs, dir)
+		}
+	}
+
+	return dirs, nil
+}
+
+// parseGoWorkDirs returns the used module directories and local replace
+// directories declared in a go.work file
+func parseGoWorkDirs(workPath string) ([]string, error) {
+	data, err := ioutil.ReadFile(workPath)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := modfile.ParseWork(workPath, data, nil)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't parse \"%s\": %v", workPath, err)
+	}
+
+	baseDir := filepath.Dir(workPath)
+
+	var dirs []string
+	for _, u := range f.Use {
+		dirs = append(dirs, resolveModulePath(baseDir, u.Path))
+	}
+
+	for _, r := range f.Replace {
+		if dir, ok := localReplaceDir(baseDir, r); ok {
+			dirs = append(dirs, dir)
+		}
+	}
+
+	return dirs, nil
+}
+
+// localReplaceDir resolves the directory of a replace directive
+// only if it points to a path in the file system
+func localReplaceDir(baseDir string, r *modfile.Replace) (string, bool) {
+	if r.New.Version != "" || !modfile.IsDirectoryPath(r.New.Path) {
+		return "", false
+	}
+
+	return resolveModulePath(baseDir, r.New.Path), true
+}
+
+func resolveModulePath(baseDir string, path string) string {
+	if filepath.IsAbs(path) {
+		return filepath.Clean(path)
+	}
+
+	return filepath.Join(baseDir, filepath.FromSlash(path))
+}
+
+// workspaceEnv resolves the environment used to build the program in dir.
+// It pins GOWORK to the detected go.work file so the workspace mode is honoured
+// regardless of where the build runs, or turns the workspace mode off if disabled.
+func workspaceEnv(dir string, disableWorkspace bool) []string {
+	if disableWorkspace {
+		return []string{"GOWORK=off"}
+	}
+
+	absDir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil
+	}
+
+	workPath := findGoWork(absDir)
+	if workPath == "" {
+		return nil
+	}
+
+	absWorkPath, err := filepath.Abs(workPath)
+	if err != nil {
+		return nil
+	}
+
+	return []string{"GOWORK=" + absWorkPath}
+}
+
+// isModuleRoot checks if the directory is the root of a Go module
+func isModuleRoot(dir string) bool {
+	info, err := os.Stat(filepath.Join(dir, goModFile))
+	return err == nil && !info.IsDir()
+}
+
+// findGoWork finds the go.work file the same way the go command does,
+// respecting the GOWORK environment variable
+func findGoWork(dir string) string {
+	gowork := os.Getenv("GOWORK")
+	if gowork == "off" {
+		return ""
+	}
+
+	if gowork != "" {
+		return gowork
+	}
+
+	return findFileUp(dir, goWorkFile)
+}
+
+// findFileUp looks for a file in the given directory and its parents
+func findFileUp(dir string, name string) string {
+	for {
+		path := filepath.Join(dir, name)
+		if info, err := os.Stat(path); err == nil && !info.IsDir() {
+			return path
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+
+		dir = parent
+	}
+}
+
+// relativePath returns path relative to the base directory when possible
+func relativePath(base string, path string) string {
+	rel, err := filepath.Rel(base, path)
+	if err != nil {
+		return path
+	}
+
+	return rel
+}
@@ -0,0 +1,60 @@
+package gaper
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestModulesLocalDirs(t *testing.T) {
+	baseDir, err := filepath.Abs(filepath.Join("testdata", "modules"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dirs, err := localModuleDirs(filepath.Join("testdata", "modules", "app"), true)
+	assert.Nil(t, err, "local module dirs error")
+	assert.Equal(t, []string{
+		filepath.Join(baseDir, "lib"),
+		filepath.Join(baseDir, "worker"),
+	}, dirs)
+}
+
+func TestModulesLocalDirsWithoutModFiles(t *testing.T) {
+	dirs, err := localModuleDirs(string(filepath.Separator), true)
+	assert.Nil(t, err, "local module dirs error")
+	assert.Len(t, dirs, 0)
+}
+
+func TestModulesFindFileUp(t *testing.T) {
+	dir, err := filepath.Abs(filepath.Join("testdata", "modules", "app"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, filepath.Join(dir, goModFile), findFileUp(dir, goModFile))
+	assert.Equal(t, filepath.Join(filepath.Dir(dir), goWorkFile), findFileUp(dir, goWorkFile))
+}
+
+func TestModulesLocalDirsWithoutWorkspace(t *testing.T) {
+	baseDir, err := filepath.Abs(filepath.Join("testdata", "modules"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dirs, err := localModuleDirs(filepath.Join("testdata", "modules", "app"), false)
+	assert.Nil(t, err, "local module dirs error")
+	assert.Equal(t, []string{filepath.Join(baseDir, "lib")}, dirs)
+}
+
+func TestModulesWorkspaceEnv(t *testing.T) {
+	workPath, err := filepath.Abs(filepath.Join("testdata", "modules", goWorkFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := filepath.Join("testdata", "modules", "app")
+	assert.Equal(t, []string{"GOWORK=" + workPath}, workspaceEnv(dir, false))
+	assert.Equal(t, []string{"GOWORK=off"}, workspaceEnv(dir, true))
+}
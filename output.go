@@ -0,0 +1,223 @@
+package gaper
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fatih/color"
+)
+
+// colors used to distinguish the output of each supervised program
+var outputColors = []color.Attribute{
+	color.FgGreen,
+	color.FgYellow,
+	color.FgBlue,
+	color.FgMagenta,
+	color.FgHiGreen,
+	color.FgHiYellow,
+	color.FgHiBlue,
+	color.FgHiMagenta,
+}
+
+// color names supported on the output settings
+var outputColorNames = map[string]color.Attribute{
+	"black":      color.FgBlack,
+	"red":        color.FgRed,
+	"green":      color.FgGreen,
+	"yellow":     color.FgYellow,
+	"blue":       color.FgBlue,
+	"magenta":    color.FgMagenta,
+	"cyan":       color.FgCyan,
+	"white":      color.FgWhite,
+	"hi-black":   color.FgHiBlack,
+	"hi-red":     color.FgHiRed,
+	"hi-green":   color.FgHiGreen,
+	"hi-yellow":  color.FgHiYellow,
+	"hi-blue":    color.FgHiBlue,
+	"hi-magenta": color.FgHiMagenta,
+	"hi-cyan":    color.FgHiCyan,
+	"hi-white":   color.FgHiWhite,
+}
+
+// OutputTimestampFormat is the time format used on prefixing the program output
+var OutputTimestampFormat = "15:04:05.000"
+
+// maxLineLength is the max size of a line kept in memory,
+// longer lines are written in chunks
+var maxLineLength = 64 * 1024
+
+// partialLineTimeout is the time waited for the rest of a line before
+// writing what has been received so far (e.g. an input prompt)
+var partialLineTimeout = 100 * time.Millisecond
+
+// outputColor picks a color for the program output based on its position
+func outputColor(index int) color.Attribute {
+	return outputColors[index%len(outputColors)]
+}
+
+// parseOutputColor resolves a color by its name
+func parseOutputColor(name string) (color.Attribute, error) {
+	if attr, ok := outputColorNames[strings.ToLower(name)]; ok {
+		return attr, nil
+	}
+
+	var names []string
+	for n := range outputColorNames {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+
+	return 0, fmt.Errorf("invalid output color \"%s\", supported colors: %s", name, strings.Join(names, ", "))
+}
+
+// outputFormat defines how each line of the program output is prefixed
+type outputFormat struct {
+	label     string
+	color     color.Attribute
+	timestamp bool
+}
+
+// enabled checks if the output needs to be prefixed at all
+func (f outputFormat) enabled() bool {
+	return f.label != "" || f.timestamp
+}
+
+// prefix builds the prefix for a line of the given stream
+func (f outputFormat) prefix(stderr bool) []byte {
+	var b strings.Builder
+
+	if f.timestamp {
+		b.WriteString(color.HiBlackString(time.Now().Format(OutputTimestampFormat)))
+		b.WriteString(" ")
+	}
+
+	label := f.label
+	if f.label != "" {
+		label = color.New(f.color).Sprint(f.label)
+	}
+
+	// stderr lines are marked so they can be told apart from stdout lines
+	if stderr {
+		if label != "" {
+			label += ":"
+		}
+		label += color.RedString("err")
+	}
+
+	if label != "" {
+		b.WriteString("[" + label + "] ")
+	}
+
+	return []byte(b.String())
+}
+
+// prefixWriter is a line oriented writer adding a prefix to every line
+type prefixWriter struct {
+	mu     sync.Mutex
+	w      io.Writer
+	format outputFormat
+	stderr bool
+	buf    []byte
+	// a partial line has already been written with its prefix
+	midLine bool
+	timer   *time.Timer
+}
+
+// newPrefixWriter creates a writer adding the prefix from the given format to every line written to w
+func newPrefixWriter(w io.Writer, format outputFormat, stderr bool) *prefixWriter {
+	return &prefixWriter{w: w, format: format, stderr: stderr}
+}
+
+// Write buffers partial lines and writes complete lines with the prefix
+func (p *prefixWriter) Write(data []byte) (int, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.timer != nil {
+		p.timer.Stop()
+	}
+
+	p.buf = append(p.buf, data...)
+	for {
+		i := bytes.IndexByte(p.buf, '\n')
+		if i < 0 {
+			break
+		}
+
+		if err := p.writeLine(p.buf[:i+1]); err != nil {
+			return 0, err
+		}
+
+		p.buf = p.buf[i+1:]
+		p.midLine = false
+	}
+
+	// very long lines are written in chunks instead of growing the buffer forever
+	for len(p.buf) >= maxLineLength {
+		if err := p.writeLine(p.buf[:maxLineLength]); err != nil {
+			return 0, err
+		}
+
+		p.buf = p.buf[maxLineLength:]
+		p.midLine = true
+	}
+
+	if len(p.buf) > 0 {
+		p.timer = time.AfterFunc(partialLineTimeout, p.flushPartial)
+	}
+
+	return len(data), nil
+}
+
+// Flush writes any pending partial line ending it with a new line
+func (p *prefixWriter) Flush() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.timer != nil {
+		p.timer.Stop()
+	}
+
+	if len(p.buf) == 0 && !p.midLine {
+		return nil
+	}
+
+	err := p.writeLine(append(p.buf, '\n'))
+	p.buf = nil
+	p.midLine = false
+	return err
+}
+
+// flushPartial writes the partial line received so far keeping the line open
+func (p *prefixWriter) flushPartial() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if len(p.buf) == 0 {
+		return
+	}
+
+	if err := p.writeLine(p.buf); err != nil {
+		logger.Debug("Error writing partial line:", err)
+	}
+
+	p.buf = nil
+	p.midLine = true
+}
+
+// writeLine writes a line or a chunk of it, only adding the prefix
+// if it is the beginning of the line
+func (p *prefixWriter) writeLine(line []byte) error {
+	out := line
+	if !p.midLine {
+		out = append(p.format.prefix(p.stderr), line...)
+	}
+
+	_, err := p.w.Write(out)
+	return err
+}
@@ -0,0 +1,123 @@
+package gaper
+
+import (
+	"bytes"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOutputPrefixWriter(t *testing.T) {
+	buf := bytes.NewBufferString("")
+	w := newPrefixWriter(buf, outputFormat{label: "api"}, false)
+
+	_, err := w.Write([]byte("first line\nsecond "))
+	assert.Nil(t, err, "write error")
+	assert.Equal(t, "[api] first line\n", buf.String())
+
+	_, err = w.Write([]byte("line\n"))
+	assert.Nil(t, err, "write error")
+	assert.Equal(t, "[api] first line\n[api] second line\n", buf.String())
+}
+
+func TestOutputPrefixWriterStderr(t *testing.T) {
+	buf := bytes.NewBufferString("")
+
+	w := newPrefixWriter(buf, outputFormat{label: "api"}, true)
+	_, err := w.Write([]byte("failure\n"))
+	assert.Nil(t, err, "write error")
+
+	w = newPrefixWriter(buf, outputFormat{timestamp: true}, true)
+	_, err = w.Write([]byte("failure\n"))
+	assert.Nil(t, err, "write error")
+
+	lines := strings.Split(buf.String(), "\n")
+	assert.Equal(t, "[api:err] failure", lines[0])
+	assert.Regexp(t, `^\d{2}:\d{2}:\d{2}\.\d{3} \[err\] failure$`, lines[1])
+}
+
+func TestOutputPrefixWriterFlush(t *testing.T) {
+	buf := bytes.NewBufferString("")
+	w := newPrefixWriter(buf, outputFormat{label: "api"}, false)
+
+	_, err := w.Write([]byte("no new line"))
+	assert.Nil(t, err, "write error")
+	assert.Equal(t, "", buf.String())
+
+	assert.Nil(t, w.Flush())
+	assert.Equal(t, "[api] no new line\n", buf.String())
+
+	// nothing else to flush
+	assert.Nil(t, w.Flush())
+	assert.Equal(t, "[api] no new line\n", buf.String())
+}
+
+func TestOutputPrefixWriterPartialLine(t *testing.T) {
+	buf := &syncBuffer{}
+	w := newPrefixWriter(buf, outputFormat{label: "api"}, false)
+
+	_, err := w.Write([]byte("Name: "))
+	assert.Nil(t, err, "write error")
+
+	time.Sleep(partialLineTimeout * 3)
+	assert.Equal(t, "[api] Name: ", buf.String())
+
+	_, err = w.Write([]byte("gaper\n"))
+	assert.Nil(t, err, "write error")
+	assert.Equal(t, "[api] Name: gaper\n", buf.String())
+}
+
+func TestOutputPrefixWriterLongLine(t *testing.T) {
+	defaultMaxLineLength := maxLineLength
+	maxLineLength = 4
+	defer func() { maxLineLength = defaultMaxLineLength }()
+
+	buf := bytes.NewBufferString("")
+	w := newPrefixWriter(buf, outputFormat{label: "api"}, false)
+
+	_, err := w.Write([]byte("123456789\nok\n"))
+	assert.Nil(t, err, "write error")
+	assert.Equal(t, "[api] 123456789\n[api] ok\n", buf.String())
+
+	_, err = w.Write([]byte("123456789"))
+	assert.Nil(t, err, "write error")
+	assert.Nil(t, w.Flush())
+	assert.Equal(t, "[api] 123456789\n[api] ok\n[api] 123456789\n", buf.String())
+}
+
+func TestOutputColor(t *testing.T) {
+	assert.Equal(t, outputColors[0], outputColor(0))
+	assert.Equal(t, outputColors[1], outputColor(len(outputColors)+1))
+}
+
+func TestOutputParseColor(t *testing.T) {
+	attr, err := parseOutputColor("Hi-Blue")
+	assert.Nil(t, err, "color error")
+	assert.Equal(t, color.FgHiBlue, attr)
+
+	_, err = parseOutputColor("pink")
+	assert.NotNil(t, err, "color error")
+	assert.Contains(t, err.Error(), "invalid output color \"pink\"")
+}
+
+// syncBuffer is a buffer safe to be written by the partial line timer
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
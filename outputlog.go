@@ -0,0 +1,72 @@
+package gaper
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// DefaultOutputLogRetention is the default number of program output log files kept
+var DefaultOutputLogRetention = 10
+
+// time format used to name the output log files, so sorting them by name sorts them by time
+const outputLogTimeFormat = "20060102-150405.000"
+
+const outputLogExt = ".log"
+
+// createOutputLog creates the file used to store the output from a program run,
+// removing the oldest files to keep up to retention files in the directory
+func createOutputLog(dir string, pid int, retention int) (*os.File, error) {
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		return nil, err
+	}
+
+	name := fmt.Sprintf("%s-%d%s", time.Now().Format(outputLogTimeFormat), pid, outputLogExt)
+	file, err := os.Create(filepath.Join(dir, name))
+	if err != nil {
+		return nil, err
+	}
+
+	if err := cleanupOutputLogs(dir, retention); err != nil {
+		logger.Warn("Error removing old program output logs:", err)
+	}
+
+	return file, nil
+}
+
+// cleanupOutputLogs removes the oldest output log files keeping up to retention files,
+// a negative retention keeps all files
+func cleanupOutputLogs(dir string, retention int) error {
+	if retention < 0 {
+		return nil
+	}
+
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+
+	var names []string
+	for _, f := range files {
+		if !f.IsDir() && strings.HasSuffix(f.Name(), outputLogExt) {
+			names = append(names, f.Name())
+		}
+	}
+
+	if len(names) <= retention {
+		return nil
+	}
+
+	sort.Strings(names)
+	for _, name := range names[:len(names)-retention] {
+		if err := os.Remove(filepath.Join(dir, name)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,74 @@
+package gaper
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOutputLogCreate(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gaper-outputlog")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir) // nolint errcheck
+
+	file, err := createOutputLog(filepath.Join(dir, "logs"), 4242, 10)
+	assert.Nil(t, err, "create error")
+	assert.Nil(t, file.Close())
+	assert.Regexp(t, regexp.MustCompile(`^\d{8}-\d{6}\.\d{3}-4242\.log$`), filepath.Base(file.Name()))
+}
+
+func TestOutputLogCleanup(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gaper-outputlog")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir) // nolint errcheck
+
+	names := []string{
+		"20220820-100000.000-1.log",
+		"20220820-100001.000-2.log",
+		"20220820-100002.000-3.log",
+		"notes.txt",
+	}
+	for _, name := range names {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	assert.Nil(t, cleanupOutputLogs(dir, 2))
+
+	files, err := ioutil.ReadDir(dir)
+	assert.Nil(t, err, "read dir error")
+
+	var remaining []string
+	for _, f := range files {
+		remaining = append(remaining, f.Name())
+	}
+	assert.Equal(t, []string{"20220820-100001.000-2.log", "20220820-100002.000-3.log", "notes.txt"}, remaining)
+
+	// a negative retention keeps all files
+	assert.Nil(t, cleanupOutputLogs(dir, -1))
+	files, err = ioutil.ReadDir(dir)
+	assert.Nil(t, err, "read dir error")
+	assert.Len(t, files, 3)
+}
+
+func TestOutputLogRetentionSetup(t *testing.T) {
+	cfg := &Config{OutputLogDir: "logs"}
+	assert.Nil(t, setupConfig(cfg), "setup error")
+	assert.Equal(t, DefaultOutputLogRetention, cfg.OutputLogRetention)
+
+	cfg = &Config{OutputLogDir: "logs", OutputLogRetention: -1}
+	assert.Nil(t, setupConfig(cfg), "setup error")
+	assert.Equal(t, -1, cfg.OutputLogRetention)
+
+	r := NewRunnerWithConfig(RunnerConfig{LogDir: "logs"})
+	assert.Equal(t, DefaultOutputLogRetention, r.(*runner).logRetention)
+}
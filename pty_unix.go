@@ -0,0 +1,53 @@
+//go:build !windows
+// +build !windows
+
+package gaper
+
+import (
+	"os"
+	"os/exec"
+	"os/signal"
+	"syscall"
+
+	"github.com/creack/pty"
+)
+
+// startPTY starts the command attached to a new pseudo-terminal,
+// returning the terminal end used by gaper
+func startPTY(cmd *exec.Cmd) (*os.File, error) {
+	return pty.Start(cmd)
+}
+
+// watchWindowSize keeps the size of the pseudo-terminal in sync with the terminal
+// gaper runs in, the returned function stops watching for size changes
+func watchWindowSize(ptmx *os.File) func() {
+	if !isTerminal(os.Stdin) {
+		return func() {}
+	}
+
+	resize := func() {
+		if err := pty.InheritSize(os.Stdin, ptmx); err != nil {
+			logger.Debug("Error resizing pseudo-terminal:", err)
+		}
+	}
+	resize()
+
+	ch := make(chan os.Signal, 1)
+	done := make(chan struct{})
+	signal.Notify(ch, syscall.SIGWINCH)
+	go func() {
+		for {
+			select {
+			case <-ch:
+				resize()
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	return func() {
+		signal.Stop(ch)
+		close(done)
+	}
+}
@@ -0,0 +1,17 @@
+package gaper
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+)
+
+// startPTY fails on Windows, where pseudo-terminals are not supported
+func startPTY(cmd *exec.Cmd) (*os.File, error) {
+	return nil, errors.New("pseudo-terminals are not supported on windows")
+}
+
+// watchWindowSize does nothing on Windows
+func watchWindowSize(ptmx *os.File) func() {
+	return func() {}
+}
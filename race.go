@@ -0,0 +1,69 @@
+package gaper
+
+import (
+	"bytes"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// lines written to stderr by the race detector around each report
+const (
+	raceReportHeader    = "WARNING: DATA RACE"
+	raceReportSeparator = "=================="
+)
+
+// raceDetector counts the data races reported by the race detector in the
+// program stderr, logging each of them once the report is complete
+type raceDetector struct {
+	name string
+	mu   sync.Mutex
+	line []byte
+	// the report being written
+	inReport bool
+	// races reported by the current run and by all runs of the program
+	races int
+	total *int32
+}
+
+// Write checks each line written for the race reports
+func (d *raceDetector) Write(data []byte) (int, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.line = append(d.line, data...)
+	for {
+		i := bytes.IndexByte(d.line, '\n')
+		if i < 0 {
+			break
+		}
+
+		line := strings.TrimSpace(string(d.line[:i]))
+		d.line = d.line[i+1:]
+
+		switch {
+		case line == raceReportHeader:
+			d.inReport = true
+		case line == raceReportSeparator && d.inReport:
+			d.inReport = false
+			d.races++
+			total := atomic.AddInt32(d.total, 1)
+			logger.ErrorWith("Data race detected in "+programName(d.name),
+				Field("races", d.races), Field("total_races", total))
+		}
+	}
+
+	// the beginning of very long lines is not kept
+	if len(d.line) > maxLineLength {
+		d.line = nil
+	}
+
+	return len(data), nil
+}
+
+// raceCount returns the data races reported by the current run
+func (d *raceDetector) raceCount() int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.races
+}
@@ -0,0 +1,30 @@
+package gaper
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRaceDetector(t *testing.T) {
+	var total int32
+	detector := &raceDetector{total: &total}
+
+	report := "==================\nWARNING: DATA RACE\nRead at 0x00c000018238 by goroutine 7:\n" +
+		"  main.main.func1()\n==================\n"
+
+	// the report is split across writes and is followed by the program output
+	detector.Write([]byte("starting\n" + report[:25])) // nolint errcheck
+	detector.Write([]byte(report[25:] + "done\n"))     // nolint errcheck
+	assert.Equal(t, 1, detector.raceCount())
+
+	detector.Write([]byte(report + report)) // nolint errcheck
+	assert.Equal(t, 3, detector.raceCount())
+	assert.Equal(t, int32(3), total)
+
+	// a separator without a report is not counted
+	detector = &raceDetector{total: &total}
+	detector.Write([]byte("==================\n")) // nolint errcheck
+	assert.Equal(t, 0, detector.raceCount())
+	assert.Equal(t, int32(3), total)
+}
@@ -0,0 +1,77 @@
+package gaper
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// DefaultReadyTimeout is the time in ms to wait for a program to be ready
+var DefaultReadyTimeout = 30000
+
+// interval between readiness checks
+var readyCheckInterval = 200 * time.Millisecond
+
+// supported readiness probes
+const (
+	readyProbeHTTP  = "http://"
+	readyProbeHTTPS = "https://"
+	readyProbeTCP   = "tcp://"
+)
+
+// validateReadyProbe checks if the readiness probe has a supported format
+func validateReadyProbe(probe string) error {
+	if strings.HasPrefix(probe, readyProbeHTTP) ||
+		strings.HasPrefix(probe, readyProbeHTTPS) ||
+		strings.HasPrefix(probe, readyProbeTCP) {
+		return nil
+	}
+
+	return fmt.Errorf("invalid readiness probe \"%s\": it must start with "+
+		"\"http://\", \"https://\" or \"tcp://\"", probe)
+}
+
+// waitReady checks the readiness probe until it succeeds or the timeout is reached
+func waitReady(probe string, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+
+	for {
+		err := checkReady(probe)
+		if err == nil {
+			return nil
+		}
+
+		if time.Now().After(deadline) {
+			return fmt.Errorf("not ready after %v: %v", timeout, err)
+		}
+
+		time.Sleep(readyCheckInterval)
+	}
+}
+
+// checkReady runs the readiness probe once
+func checkReady(probe string) error {
+	if strings.HasPrefix(probe, readyProbeTCP) {
+		conn, err := net.DialTimeout("tcp", strings.TrimPrefix(probe, readyProbeTCP), time.Second)
+		if err != nil {
+			return err
+		}
+
+		return conn.Close()
+	}
+
+	client := http.Client{Timeout: time.Second}
+	resp, err := client.Get(probe) // nolint gosec
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close() // nolint errcheck
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 400 {
+		return fmt.Errorf("unexpected status code %d", resp.StatusCode)
+	}
+
+	return nil
+}
@@ -0,0 +1,64 @@
+package gaper
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReadyValidateProbe(t *testing.T) {
+	assert.Nil(t, validateReadyProbe("http://localhost:8080/health"))
+	assert.Nil(t, validateReadyProbe("https://localhost:8080/health"))
+	assert.Nil(t, validateReadyProbe("tcp://localhost:5432"))
+
+	err := validateReadyProbe("localhost:5432")
+	assert.NotNil(t, err, "probe error")
+	assert.Contains(t, err.Error(), "invalid readiness probe \"localhost:5432\"")
+}
+
+func TestReadyCheckHTTP(t *testing.T) {
+	ready := false
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !ready {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer srv.Close()
+
+	err := checkReady(srv.URL)
+	assert.NotNil(t, err, "ready error")
+	assert.Equal(t, "unexpected status code 503", err.Error())
+
+	ready = true
+	assert.Nil(t, checkReady(srv.URL))
+}
+
+func TestReadyCheckTCP(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	probe := "tcp://" + ln.Addr().String()
+	assert.Nil(t, checkReady(probe))
+
+	ln.Close() // nolint errcheck
+	assert.NotNil(t, checkReady(probe))
+}
+
+func TestReadyWaitTimeout(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	probe := "tcp://" + ln.Addr().String()
+	ln.Close() // nolint errcheck
+
+	err = waitReady(probe, 300*time.Millisecond)
+	assert.NotNil(t, err, "wait error")
+	assert.Contains(t, err.Error(), "not ready after 300ms")
+}
@@ -0,0 +1,188 @@
+package gaper
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// RestartDecision is what to do once a supervised program exits
+type RestartDecision int
+
+// decisions supported by the restart policies
+const (
+	// restarts the program right away
+	RestartNow RestartDecision = iota
+	// keeps the program stopped until a watched file changes
+	RestartWait
+	// stops the other programs and gaper
+	RestartQuit
+)
+
+// names of the built-in restart policies
+const (
+	RestartPolicyAlways          = "always"
+	RestartPolicyOnFailure       = "on-failure"
+	RestartPolicyNever           = "never"
+	RestartPolicyUnlessExitCodes = "unless-exit-codes"
+	RestartPolicyMaxRetries      = "max-retries"
+)
+
+// RestartPolicy decides what to do once a supervised program exits on its own
+type RestartPolicy interface {
+	Decide(exit ExitInfo) RestartDecision
+}
+
+// RestartPolicyFunc allows using a function as a restart policy
+type RestartPolicyFunc func(exit ExitInfo) RestartDecision
+
+// Decide calls the function
+func (f RestartPolicyFunc) Decide(exit ExitInfo) RestartDecision {
+	return f(exit)
+}
+
+// AlwaysRestart restarts the program regardless of the exit code
+type AlwaysRestart struct{}
+
+// Decide always restarts
+func (AlwaysRestart) Decide(exit ExitInfo) RestartDecision {
+	return RestartNow
+}
+
+// OnFailureRestart restarts the program only if it failed
+type OnFailureRestart struct{}
+
+// Decide restarts on a non zero exit code
+func (OnFailureRestart) Decide(exit ExitInfo) RestartDecision {
+	if exit.Success() {
+		return RestartWait
+	}
+
+	return RestartNow
+}
+
+// NeverRestart keeps the program stopped until a watched file changes
+type NeverRestart struct{}
+
+// Decide never restarts
+func (NeverRestart) Decide(exit ExitInfo) RestartDecision {
+	return RestartWait
+}
+
+// UnlessExitCodesRestart restarts the program unless it exits with one of the codes
+type UnlessExitCodesRestart struct {
+	Codes []int
+}
+
+// Decide restarts if the exit code is not in the list
+func (p UnlessExitCodesRestart) Decide(exit ExitInfo) RestartDecision {
+	for _, code := range p.Codes {
+		if exit.ExitCode == code {
+			return RestartWait
+		}
+	}
+
+	return RestartNow
+}
+
+// MaxRetriesRestart restarts the program on failures up to
+// Max consecutive times, keeping it stopped afterwards
+type MaxRetriesRestart struct {
+	Max int
+}
+
+// Decide restarts on a non zero exit code until reaching the max retries
+func (p MaxRetriesRestart) Decide(exit ExitInfo) RestartDecision {
+	if exit.Success() || exit.Restarts >= p.Max {
+		return RestartWait
+	}
+
+	return RestartNow
+}
+
+// ParseRestartPolicy creates one of the built-in restart policies from its name,
+// the policies with arguments have them after a colon (e.g. "max-retries:5")
+func ParseRestartPolicy(value string) (RestartPolicy, error) {
+	name, arg := value, ""
+	if i := strings.Index(value, ":"); i >= 0 {
+		name, arg = value[:i], value[i+1:]
+	}
+
+	switch {
+	case name == RestartPolicyAlways && arg == "":
+		return AlwaysRestart{}, nil
+	case name == RestartPolicyOnFailure && arg == "":
+		return OnFailureRestart{}, nil
+	case name == RestartPolicyNever && arg == "":
+		return NeverRestart{}, nil
+	case name == RestartPolicyUnlessExitCodes && arg != "":
+		var codes []int
+		for _, item := range strings.Split(arg, ",") {
+			code, err := strconv.Atoi(strings.TrimSpace(item))
+			if err != nil {
+				return nil, fmt.Errorf("invalid exit code \"%s\" on restart policy \"%s\"", item, value)
+			}
+			codes = append(codes, code)
+		}
+		return UnlessExitCodesRestart{Codes: codes}, nil
+	case name == RestartPolicyMaxRetries && arg != "":
+		max, err := strconv.Atoi(arg)
+		if err != nil || max < 0 {
+			return nil, fmt.Errorf("invalid max retries \"%s\" on restart policy \"%s\"", arg, value)
+		}
+		return MaxRetriesRestart{Max: max}, nil
+	}
+
+	return nil, fmt.Errorf("invalid restart policy \"%s\", supported policies: %s, %s, %s, %s:<codes>, %s:<n>",
+		value, RestartPolicyAlways, RestartPolicyOnFailure, RestartPolicyNever,
+		RestartPolicyUnlessExitCodes, RestartPolicyMaxRetries)
+}
+
+// noRestartOnPolicy converts the legacy no restart on setting to a restart policy
+func noRestartOnPolicy(noRestartOn string) (RestartPolicy, error) {
+	switch noRestartOn {
+	case "":
+		return AlwaysRestart{}, nil
+	case NoRestartOnError:
+		// an exit code of 0 will still restart
+		return RestartPolicyFunc(func(exit ExitInfo) RestartDecision {
+			if exit.Success() {
+				return RestartNow
+			}
+			return RestartWait
+		}), nil
+	case NoRestartOnSuccess:
+		return OnFailureRestart{}, nil
+	case NoRestartOnExit:
+		return NeverRestart{}, nil
+	}
+
+	return nil, fmt.Errorf("invalid no restart on \"%s\", supported values: %s, %s, %s",
+		noRestartOn, NoRestartOnError, NoRestartOnSuccess, NoRestartOnExit)
+}
+
+// restartPolicy resolves the restart policy of a service, or of the main program when svc
+// is nil. The restart settings of a service have priority over the main ones, which the
+// services without their own settings use, including the custom policy
+func (cfg *Config) restartPolicy(svc *ServiceConfig) (RestartPolicy, error) {
+	if svc != nil && (svc.RestartPolicy != "" || svc.NoRestartOn != "") {
+		return resolveRestartPolicy(nil, svc.RestartPolicy, svc.NoRestartOn)
+	}
+
+	return resolveRestartPolicy(cfg.CustomRestartPolicy, cfg.RestartPolicy, cfg.NoRestartOn)
+}
+
+// resolveRestartPolicy picks the restart policy from the settings, a custom
+// policy has priority over a restart policy name, which has priority over
+// the legacy no restart on setting
+func resolveRestartPolicy(custom RestartPolicy, name string, noRestartOn string) (RestartPolicy, error) {
+	if custom != nil {
+		return custom, nil
+	}
+
+	if name != "" {
+		return ParseRestartPolicy(name)
+	}
+
+	return noRestartOnPolicy(noRestartOn)
+}
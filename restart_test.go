@@ -0,0 +1,112 @@
+package gaper
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseRestartPolicy(t *testing.T) {
+	testCases := []struct {
+		value    string
+		expected RestartPolicy
+		err      string
+	}{
+		{value: "always", expected: AlwaysRestart{}},
+		{value: "on-failure", expected: OnFailureRestart{}},
+		{value: "never", expected: NeverRestart{}},
+		{value: "unless-exit-codes:0, 3", expected: UnlessExitCodesRestart{Codes: []int{0, 3}}},
+		{value: "max-retries:5", expected: MaxRetriesRestart{Max: 5}},
+		{value: "unless-exit-codes:a", err: "invalid exit code \"a\" on restart policy \"unless-exit-codes:a\""},
+		{value: "max-retries:-1", err: "invalid max retries \"-1\" on restart policy \"max-retries:-1\""},
+		{value: "max-retries", err: "invalid restart policy \"max-retries\", supported policies: " +
+			"always, on-failure, never, unless-exit-codes:<codes>, max-retries:<n>"},
+		{value: "always:1", err: "invalid restart policy \"always:1\", supported policies: " +
+			"always, on-failure, never, unless-exit-codes:<codes>, max-retries:<n>"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.value, func(t *testing.T) {
+			policy, err := ParseRestartPolicy(tc.value)
+			if tc.err != "" {
+				assert.NotNil(t, err, "policy error")
+				assert.Equal(t, tc.err, err.Error())
+				return
+			}
+
+			assert.Nil(t, err, "policy error")
+			assert.Equal(t, tc.expected, policy)
+		})
+	}
+}
+
+func TestRestartPolicyDecide(t *testing.T) {
+	success := ExitInfo{ExitCode: 0}
+	failure := ExitInfo{ExitCode: 2}
+	signaled := ExitInfo{ExitCode: -1}
+
+	testCases := []struct {
+		name     string
+		policy   RestartPolicy
+		exit     ExitInfo
+		expected RestartDecision
+	}{
+		{name: "always on success", policy: AlwaysRestart{}, exit: success, expected: RestartNow},
+		{name: "always on failure", policy: AlwaysRestart{}, exit: failure, expected: RestartNow},
+		{name: "on failure on success", policy: OnFailureRestart{}, exit: success, expected: RestartWait},
+		{name: "on failure on failure", policy: OnFailureRestart{}, exit: failure, expected: RestartNow},
+		{name: "on failure on signal", policy: OnFailureRestart{}, exit: signaled, expected: RestartNow},
+		{name: "never", policy: NeverRestart{}, exit: failure, expected: RestartWait},
+		{name: "unless listed code", policy: UnlessExitCodesRestart{Codes: []int{0, 2}}, exit: failure, expected: RestartWait},
+		{name: "unless other code", policy: UnlessExitCodesRestart{Codes: []int{0, 3}}, exit: failure, expected: RestartNow},
+		{name: "max retries not reached", policy: MaxRetriesRestart{Max: 3}, exit: ExitInfo{ExitCode: 1, Restarts: 2}, expected: RestartNow},
+		{name: "max retries reached", policy: MaxRetriesRestart{Max: 3}, exit: ExitInfo{ExitCode: 1, Restarts: 3}, expected: RestartWait},
+		{name: "max retries on success", policy: MaxRetriesRestart{Max: 3}, exit: success, expected: RestartWait},
+		{
+			name:     "func",
+			policy:   RestartPolicyFunc(func(exit ExitInfo) RestartDecision { return RestartQuit }),
+			exit:     success,
+			expected: RestartQuit,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, tc.policy.Decide(tc.exit))
+		})
+	}
+}
+
+func TestResolveRestartPolicy(t *testing.T) {
+	custom := RestartPolicyFunc(func(exit ExitInfo) RestartDecision { return RestartQuit })
+
+	policy, err := resolveRestartPolicy(custom, "never", NoRestartOnExit)
+	assert.Nil(t, err, "policy error")
+	assert.Equal(t, RestartQuit, policy.Decide(ExitInfo{}))
+
+	policy, err = resolveRestartPolicy(nil, "on-failure", NoRestartOnExit)
+	assert.Nil(t, err, "policy error")
+	assert.Equal(t, OnFailureRestart{}, policy)
+
+	policy, err = resolveRestartPolicy(nil, "", "")
+	assert.Nil(t, err, "policy error")
+	assert.Equal(t, AlwaysRestart{}, policy)
+
+	// legacy no restart on values
+	policy, err = resolveRestartPolicy(nil, "", NoRestartOnError)
+	assert.Nil(t, err, "policy error")
+	assert.Equal(t, RestartNow, policy.Decide(ExitInfo{ExitCode: 0}))
+	assert.Equal(t, RestartWait, policy.Decide(ExitInfo{ExitCode: 2}))
+
+	policy, err = resolveRestartPolicy(nil, "", NoRestartOnSuccess)
+	assert.Nil(t, err, "policy error")
+	assert.Equal(t, OnFailureRestart{}, policy)
+
+	policy, err = resolveRestartPolicy(nil, "", NoRestartOnExit)
+	assert.Nil(t, err, "policy error")
+	assert.Equal(t, NeverRestart{}, policy)
+
+	_, err = resolveRestartPolicy(nil, "", "invalid")
+	assert.NotNil(t, err, "policy error")
+	assert.Equal(t, "invalid no restart on \"invalid\", supported values: error, success, exit", err.Error())
+}
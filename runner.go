@@ -7,7 +7,7 @@ import (
 	"os"
 	"os/exec"
 	"runtime"
-	"syscall"
+	"sync"
 	"time"
 )
 
@@ -25,36 +25,111 @@ type Runner interface {
 	Exited() bool
 	IsRunning() bool
 	ExitStatus(err error) int
+	Signal(sig os.Signal) error
 }
 
 type runner struct {
+	name         string
 	bin          string
 	args         []string
 	writerStdout io.Writer
 	writerStderr io.Writer
+	logDir       string
+	logRetention int
 	command      *exec.Cmd
 	starttime    time.Time
 	errors       chan error
-	end          chan bool // used internally by Kill to wait a process die
+	end          chan bool     // used internally by Kill to wait a process die
+	waited       chan struct{} // closed once the current process has been waited for
+	// runs the program in a pseudo-terminal
+	pty bool
+	// signals are sent to the whole process group of the program
+	processGroup bool
+	// forwards the input to the program, nil when the program gets no input
+	input *inputForwarder
+	// address the debugger the program runs under listens on, empty to run the program directly
+	debugAddr string
+	// command the program runs through (e.g. "strace -f")
+	wrapper []string
+	// directory where each run writes its coverage data to, empty when not collected
+	coverDir string
+	// data races reported by all runs of the program
+	races int32
+	// details about the exits not yet inspected, by their wait error
+	exitsMu sync.Mutex
+	exits   map[error]exitDetails
+}
+
+// RunnerConfig defines the settings available for the runner
+type RunnerConfig struct {
+	Name   string
+	Stdout io.Writer
+	Stderr io.Writer
+	Bin    string
+	Args   []string
+	// directory where the output from each run is also written to, keeping up to
+	// LogRetention files (DefaultOutputLogRetention when 0), a negative value keeps all files
+	LogDir       string
+	LogRetention int
+	// runs the program attached to a pseudo-terminal, so it behaves as if it was run
+	// directly in a terminal (e.g. with colors), stdout and stderr are merged in Stdout
+	PTY bool
+	// starts the program in its own process group, so the signals forwarded
+	// to the program also reach the processes started by it
+	ProcessGroup bool
+	// runs the program under a headless debugger listening on this address,
+	// which is started again on every run so the debug clients can reattach,
+	// the debugger starts its own process group so the signals also reach the program
+	DebugAddr string
+	// command the program runs through (e.g. "time" or "strace -f"), which starts
+	// its own process group so the signals also reach the program
+	Wrapper []string
+	// directory where the coverage data of each run is written to, on a new
+	// directory set as GOCOVERDIR for the program built with coverage
+	CoverDir string
+	// forwards the input read by gaper to the program
+	input *inputForwarder
 }
 
 // NewRunner creates a new runner
 func NewRunner(wStdout io.Writer, wStderr io.Writer, bin string, args []string) Runner {
+	return NewRunnerWithConfig(RunnerConfig{
+		Stdout: wStdout,
+		Stderr: wStderr,
+		Bin:    bin,
+		Args:   args,
+	})
+}
+
+// NewRunnerWithConfig creates a new runner based on the given settings
+func NewRunnerWithConfig(cfg RunnerConfig) Runner {
+	if cfg.LogRetention == 0 {
+		cfg.LogRetention = DefaultOutputLogRetention
+	}
+
 	return &runner{
-		bin:          bin,
-		args:         args,
-		writerStdout: wStdout,
-		writerStderr: wStderr,
+		name:         cfg.Name,
+		bin:          cfg.Bin,
+		args:         cfg.Args,
+		writerStdout: cfg.Stdout,
+		writerStderr: cfg.Stderr,
+		logDir:       cfg.LogDir,
+		logRetention: cfg.LogRetention,
+		pty:          cfg.PTY,
+		processGroup: cfg.ProcessGroup,
+		input:        cfg.input,
+		debugAddr:    cfg.DebugAddr,
+		wrapper:      cfg.Wrapper,
+		coverDir:     cfg.CoverDir,
 		starttime:    time.Now(),
 		errors:       make(chan error),
 		end:          make(chan bool),
+		exits:        map[error]exitDetails{},
 	}
 }
 
 // Run executes the project binary
 func (r *runner) Run() (*exec.Cmd, error) {
-	logger.Info("Starting program")
-
 	if r.command != nil && !r.Exited() {
 		return r.command, nil
 	}
@@ -72,6 +147,13 @@ func (r *runner) Kill() error { // nolint gocyclo
 		return nil
 	}
 
+	// the program has already finished (e.g. quitting after its exit)
+	select {
+	case <-r.waited:
+		return nil
+	default:
+	}
+
 	done := make(chan error)
 	go func() {
 		<-r.end
@@ -80,17 +162,17 @@ func (r *runner) Kill() error { // nolint gocyclo
 
 	// Trying a "soft" kill first
 	if runtime.GOOS == OSWindows {
-		if err := r.command.Process.Kill(); err != nil {
+		if err := r.signal(os.Kill); err != nil {
 			return err
 		}
-	} else if err := r.command.Process.Signal(os.Interrupt); err != nil {
+	} else if err := r.signal(os.Interrupt); err != nil {
 		return err
 	}
 
 	// Wait for our process to die before we return or hard kill after 3 sec
 	select {
 	case <-time.After(3 * time.Second):
-		if err := r.command.Process.Kill(); err != nil {
+		if err := r.signal(os.Kill); err != nil {
 			errMsg := err.Error()
 			// ignore error if the processed has been killed already
 			if errMsg != errFinished.Error() && errMsg != os.ErrInvalid.Error() {
@@ -119,46 +201,266 @@ func (r *runner) Errors() chan error {
 	return r.errors
 }
 
+// Signal sends the signal to the running program, or to its process group
+func (r *runner) Signal(sig os.Signal) error {
+	if r.command == nil || r.command.Process == nil {
+		return nil
+	}
+
+	// ignore error if the process has finished already
+	if err := r.signal(sig); err != nil && err.Error() != errFinished.Error() {
+		return err
+	}
+
+	return nil
+}
+
+// signal sends the signal to the program, or to its process group when it has its own
+func (r *runner) signal(sig os.Signal) error {
+	if r.ownProcessGroup() {
+		return signalProcessGroup(r.command.Process, sig)
+	}
+
+	return r.command.Process.Signal(sig)
+}
+
+// ownProcessGroup checks if the program runs in its own process group, which is always
+// the case when it runs through a wrapper or the debugger so the signals don't stop
+// only them, leaving the program behind
+func (r *runner) ownProcessGroup() bool {
+	return r.processGroup || len(r.wrapper) > 0 || r.debugAddr != ""
+}
+
 // ExitStatus resolves the exit status
 func (r *runner) ExitStatus(err error) int {
-	var exitStatus int
-	if exiterr, ok := err.(*exec.ExitError); ok {
-		if status, oks := exiterr.Sys().(syscall.WaitStatus); oks {
-			exitStatus = status.ExitStatus()
-		}
+	return exitStatus(err)
+}
+
+// inspectExit adds the details collected while the program was running to its exit
+func (r *runner) inspectExit(err error, exit *ExitInfo) {
+	if err == nil {
+		return
 	}
 
-	return exitStatus
+	r.exitsMu.Lock()
+	details, ok := r.exits[err]
+	delete(r.exits, err)
+	r.exitsMu.Unlock()
+
+	if ok {
+		exit.Panic = details.panic
+		exit.OOMKilled = details.oomKilled
+		exit.Races = details.races
+	}
 }
 
 func (r *runner) runBin() error {
-	r.command = exec.Command(r.bin, r.args...) // nolint gas
-	stdout, err := r.command.StdoutPipe()
-	if err != nil {
-		return err
+	bin, args := r.bin, r.args
+	if r.debugAddr != "" {
+		bin, args = debugCommand(r.debugAddr, r.bin, r.args)
 	}
 
-	stderr, err := r.command.StderrPipe()
-	if err != nil {
-		return err
+	if len(r.wrapper) > 0 {
+		args = append(append(append([]string{}, r.wrapper[1:]...), bin), args...)
+		bin = r.wrapper[0]
 	}
 
-	// TODO: handle or log errors
-	go io.Copy(r.writerStdout, stdout) // nolint errcheck
-	go io.Copy(r.writerStderr, stderr) // nolint errcheck
+	r.command = exec.Command(bin, args...) // nolint gas
+	// a pseudo-terminal already starts the program in its own session and process group
+	if r.ownProcessGroup() && !r.pty {
+		setProcessGroup(r.command)
+	}
+	oomKills, oomKillsAvailable := readOOMKills()
+
+	if r.coverDir != "" {
+		coverDir, err := newCoverRunDir(r.coverDir)
+		if err != nil {
+			logger.Warn("Error creating coverage directory:", err)
+		} else {
+			r.command.Env = append(os.Environ(), "GOCOVERDIR="+coverDir)
+		}
+	}
 
-	err = r.command.Start()
+	stdout, stderr, cleanup, err := r.start()
 	if err != nil {
 		return err
 	}
 
 	r.starttime = time.Now()
+	logger.InfoWith("Starting "+programName(r.name), Field("pid", r.command.Process.Pid))
+	if r.debugAddr != "" {
+		logger.Info("Debugger of " + programName(r.name) + " listening on " + r.debugAddr)
+	}
+
+	// the output is also written to a log file for this run
+	var outputLog *os.File
+	if r.logDir != "" {
+		outputLog, err = createOutputLog(r.logDir, r.command.Process.Pid, r.logRetention)
+		if err != nil {
+			logger.Warn("Error creating program output log:", err)
+		} else {
+			logger.DebugWith("Writing program output to log file", Field("file", outputLog.Name()))
+		}
+	}
+
+	// wait for the whole output to be copied before waiting for the command to finish,
+	// otherwise the pipes could be closed before all the output has been read
+	var stdoutTees, stderrTees []io.Writer
+	if outputLog != nil {
+		stdoutTees = append(stdoutTees, outputLog)
+		stderrTees = append(stderrTees, outputLog)
+	}
+
+	// stderr is checked for Go panics to describe the exit and for data races
+	detector := &panicDetector{}
+	races := &raceDetector{name: r.name, total: &r.races}
+	stderrTees = append(stderrTees, detector, races)
+
+	var wg sync.WaitGroup
+	if stderr == nil {
+		// there is a single output on a pseudo-terminal
+		wg.Add(1)
+		go r.copyOutput(&wg, r.writerStdout, stdout, append(stdoutTees, detector, races)...)
+	} else {
+		wg.Add(2)
+		go r.copyOutput(&wg, r.writerStdout, stdout, stdoutTees...)
+		go r.copyOutput(&wg, r.writerStderr, stderr, stderrTees...)
+	}
 
 	// wait for exit errors
+	command, waited := r.command, make(chan struct{})
+	r.waited = waited
 	go func() {
-		r.errors <- r.command.Wait()
+		wg.Wait()
+		if outputLog != nil {
+			if err := outputLog.Close(); err != nil {
+				logger.Debug("Error closing program output log:", err)
+			}
+		}
+
+		cleanup()
+		err := command.Wait()
+		close(waited)
+		if err != nil {
+			r.exitsMu.Lock()
+			r.exits[err] = exitDetails{
+				panic:     detector.panicLine(),
+				races:     races.raceCount(),
+				oomKilled: killedByOOM(err, r.ExitStatus(err), oomKills, oomKillsAvailable),
+			}
+			r.exitsMu.Unlock()
+		}
+
+		r.errors <- err
 		r.end <- true
 	}()
 
 	return nil
 }
+
+// start starts the program returning its stdout and stderr, which is nil when the program
+// runs in a pseudo-terminal. The returned function releases the resources used by the
+// program once its output is over.
+func (r *runner) start() (io.Reader, io.Reader, func(), error) {
+	if r.pty {
+		ptmx, err := startPTY(r.command)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+
+		stopResize := watchWindowSize(ptmx)
+		if r.input != nil {
+			r.input.attach(ptmx, func() {
+				ptmx.Write([]byte{eotKey}) // nolint errcheck
+			})
+		}
+
+		return ptmx, nil, func() {
+			stopResize()
+			if r.input != nil {
+				r.input.detach(ptmx)
+			}
+			ptmx.Close() // nolint errcheck
+		}, nil
+	}
+
+	stdout, err := r.command.StdoutPipe()
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	stderr, err := r.command.StderrPipe()
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	var stdin io.WriteCloser
+	if r.input != nil {
+		if stdin, err = r.command.StdinPipe(); err != nil {
+			return nil, nil, nil, err
+		}
+	}
+
+	if err := r.command.Start(); err != nil {
+		return nil, nil, nil, err
+	}
+
+	if stdin == nil {
+		return stdout, stderr, func() {}, nil
+	}
+
+	r.input.attach(stdin, func() {
+		stdin.Close() // nolint errcheck
+	})
+	return stdout, stderr, func() { r.input.detach(stdin) }, nil
+}
+
+// teeWriter writes a copy of the program output (e.g. to the output log file). The
+// first error is logged and the next writes are discarded, so a failing copy doesn't
+// stop the output from being read, which would block the program on a full pipe.
+type teeWriter struct {
+	w      io.Writer
+	failed bool
+}
+
+// Write writes to the copy until it fails, always reporting success
+func (t *teeWriter) Write(data []byte) (int, error) {
+	if !t.failed {
+		if _, err := t.w.Write(data); err != nil {
+			logger.Warn("Error writing a copy of the program output, no longer written to it:", err)
+			t.failed = true
+		}
+	}
+
+	return len(data), nil
+}
+
+// flusher is implemented by writers buffering the program output
+type flusher interface {
+	Flush() error
+}
+
+// copyOutput copies the program output to the writer and to the tee writers,
+// flushing the writer once the output is over
+func (r *runner) copyOutput(wg *sync.WaitGroup, w io.Writer, output io.Reader, tees ...io.Writer) {
+	defer wg.Done()
+
+	dst := w
+	if len(tees) > 0 {
+		writers := []io.Writer{w}
+		for _, tee := range tees {
+			writers = append(writers, &teeWriter{w: tee})
+		}
+		dst = io.MultiWriter(writers...)
+	}
+
+	if _, err := io.Copy(dst, output); err != nil {
+		logger.Debug("Error copying program output:", err)
+	}
+
+	if f, ok := w.(flusher); ok {
+		if err := f.Flush(); err != nil {
+			logger.Debug("Error flushing program output:", err)
+		}
+	}
+}
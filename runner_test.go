@@ -3,11 +3,16 @@ package gaper
 import (
 	"bytes"
 	"errors"
+	"io/ioutil"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"runtime"
+	"strings"
+	"sync"
 	"testing"
+	"testing/iotest"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -33,6 +38,65 @@ func TestRunnerSuccessRun(t *testing.T) {
 	assert.Equal(t, stderr.String(), "")
 }
 
+func TestRunnerOutputLog(t *testing.T) {
+	if runtime.GOOS == OSWindows {
+		t.Skip("print-gaper.bat output differs on windows")
+	}
+
+	dir, err := ioutil.TempDir("", "gaper-runner")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir) // nolint errcheck
+
+	stdout := bytes.NewBufferString("")
+	runner := NewRunnerWithConfig(RunnerConfig{
+		Stdout:       stdout,
+		Stderr:       ioutil.Discard,
+		Bin:          filepath.Join("testdata", "print-gaper"),
+		LogDir:       dir,
+		LogRetention: 10,
+	})
+
+	_, err = runner.Run()
+	assert.Nil(t, err, "error running binary")
+	assert.Nil(t, <-runner.Errors(), "async error running binary")
+
+	files, err := ioutil.ReadDir(dir)
+	assert.Nil(t, err, "read dir error")
+	assert.Len(t, files, 1)
+
+	data, err := ioutil.ReadFile(filepath.Join(dir, files[0].Name()))
+	assert.Nil(t, err, "read error")
+	assert.Equal(t, "Gaper Test Message\n", string(data))
+	assert.Equal(t, "Gaper Test Message\n", stdout.String())
+}
+
+func TestRunnerCopyOutputFailingTee(t *testing.T) {
+	stdout := bytes.NewBufferString("")
+	tee := &failingWriter{}
+	output := iotest.OneByteReader(strings.NewReader(strings.Repeat("line\n", 1000)))
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	r := &runner{}
+	r.copyOutput(&wg, stdout, output, tee)
+
+	// the output keeps being copied after the tee fails
+	assert.Equal(t, strings.Repeat("line\n", 1000), stdout.String())
+	assert.Equal(t, 1, tee.writes)
+}
+
+// failingWriter fails on every write
+type failingWriter struct {
+	writes int
+}
+
+func (w *failingWriter) Write(data []byte) (int, error) {
+	w.writes++
+	return 0, errors.New("no space left on device")
+}
+
 func TestRunnerSuccessKill(t *testing.T) {
 	bin := filepath.Join("testdata", "print-gaper")
 	if runtime.GOOS == OSWindows {
@@ -79,3 +143,177 @@ func TestRunnerExitStatusExitError(t *testing.T) {
 	runner := NewRunner(os.Stdout, os.Stderr, "", nil)
 	assert.Equal(t, runner.ExitStatus(err), 1)
 }
+
+func TestRunnerInspectExitPanic(t *testing.T) {
+	if runtime.GOOS == OSWindows {
+		t.Skip("print-panic is not available on windows")
+	}
+
+	stderr := bytes.NewBufferString("")
+	runner := NewRunner(ioutil.Discard, stderr, filepath.Join("testdata", "print-panic"), nil)
+
+	_, err := runner.Run()
+	assert.Nil(t, err, "error running binary")
+
+	errCmd := <-runner.Errors()
+	assert.NotNil(t, errCmd, "async error running binary")
+	assert.Contains(t, stderr.String(), "goroutine 1 [running]:")
+
+	exit := ExitInfo{ExitCode: runner.ExitStatus(errCmd)}
+	runner.(exitInspector).inspectExit(errCmd, &exit)
+	assert.Equal(t, 2, exit.ExitCode)
+	assert.Equal(t, "panic: something went wrong", exit.Panic)
+	assert.False(t, exit.OOMKilled)
+	assert.Equal(t, "exited with code 2, panic: something went wrong", exit.Summary())
+}
+
+func TestRunnerStdin(t *testing.T) {
+	if runtime.GOOS == OSWindows {
+		t.Skip("cat is not available on windows")
+	}
+
+	stdout := bytes.NewBufferString("")
+	input := &inputForwarder{}
+	runner := NewRunnerWithConfig(RunnerConfig{
+		Stdout: stdout,
+		Stderr: ioutil.Discard,
+		Bin:    "cat",
+		input:  input,
+	})
+
+	input.Write([]byte("Gaper Test Input\n")) // nolint errcheck
+	_, err := runner.Run()
+	assert.Nil(t, err, "error running binary")
+
+	input.close()
+	assert.Nil(t, <-runner.Errors(), "async error running binary")
+	assert.Equal(t, "Gaper Test Input\n", stdout.String())
+}
+
+func TestRunnerExecWrapper(t *testing.T) {
+	if runtime.GOOS == OSWindows {
+		t.Skip("sh is not available on windows")
+	}
+
+	stdout := bytes.NewBufferString("")
+	runner := NewRunnerWithConfig(RunnerConfig{
+		Stdout:  stdout,
+		Stderr:  ioutil.Discard,
+		Bin:     "echo",
+		Args:    []string{"program"},
+		Wrapper: []string{"sh", "-c", `echo wrapper; "$@"`, "wrapper"},
+	})
+
+	_, err := runner.Run()
+	assert.Nil(t, err, "error running binary")
+	assert.Nil(t, <-runner.Errors(), "async error running binary")
+	assert.Equal(t, "wrapper\nprogram\n", stdout.String())
+}
+
+func TestRunnerExecWrapperKill(t *testing.T) {
+	if runtime.GOOS == OSWindows {
+		t.Skip("sh is not available on windows")
+	}
+
+	dir, err := ioutil.TempDir("", "gaper-wrapper")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir) // nolint errcheck
+
+	// the wrapper waits for the program, so it only ends once the program is killed too
+	ready := filepath.Join(dir, "ready")
+	runner := NewRunnerWithConfig(RunnerConfig{
+		Stdout:  ioutil.Discard,
+		Stderr:  ioutil.Discard,
+		Bin:     "sleep",
+		Args:    []string{"30"},
+		Wrapper: []string{"sh", "-c", `touch "$0"; "$@"; echo after`, ready},
+	})
+
+	_, err = runner.Run()
+	assert.Nil(t, err, "error running binary")
+
+	errs := make(chan error, 1)
+	go func() {
+		errs <- <-runner.Errors()
+	}()
+
+	// wait for the wrapper to start the program
+	for i := 0; i < 100; i++ {
+		if _, err := os.Stat(ready); err == nil {
+			break
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+
+	start := time.Now()
+	assert.Nil(t, runner.Kill(), "error killing program")
+	assert.True(t, time.Since(start) < 3*time.Second, "program killed without a hard kill")
+	assert.NotNil(t, <-errs, "kill program")
+}
+
+func TestRunnerDebugKill(t *testing.T) {
+	if runtime.GOOS == OSWindows {
+		t.Skip("sh is not available on windows")
+	}
+
+	dir, err := ioutil.TempDir("", "gaper-debug")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir) // nolint errcheck
+
+	// the fake debugger only handles the interrupt once the program ends, so the
+	// program must be interrupted too to stop the debugger without a hard kill
+	debugger := filepath.Join(dir, "dlv")
+	script := "#!/bin/sh\ntrap \"exit 1\" INT\nsleep 30\n"
+	if err := ioutil.WriteFile(debugger, []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	defaultDebuggerBin := debuggerBin
+	debuggerBin = debugger
+	defer func() { debuggerBin = defaultDebuggerBin }()
+
+	runner := NewRunnerWithConfig(RunnerConfig{
+		Stdout:    ioutil.Discard,
+		Stderr:    ioutil.Discard,
+		Bin:       "srv",
+		DebugAddr: ":2345",
+	})
+
+	_, err = runner.Run()
+	assert.Nil(t, err, "error running debugger")
+
+	errs := make(chan error, 1)
+	go func() {
+		errs <- <-runner.Errors()
+	}()
+	time.Sleep(200 * time.Millisecond)
+
+	start := time.Now()
+	assert.Nil(t, runner.Kill(), "error killing debugger")
+	assert.True(t, time.Since(start) < 3*time.Second, "debugger killed without a hard kill")
+	assert.NotNil(t, <-errs, "kill debugger")
+}
+
+func TestRunnerPTY(t *testing.T) {
+	if runtime.GOOS == OSWindows {
+		t.Skip("pseudo-terminals are not supported on windows")
+	}
+
+	stdout := bytes.NewBufferString("")
+	runner := NewRunnerWithConfig(RunnerConfig{
+		Stdout: stdout,
+		Stderr: ioutil.Discard,
+		Bin:    "sh",
+		Args:   []string{"-c", "test -t 0 && test -t 1 && echo terminal >&2"},
+		PTY:    true,
+	})
+
+	_, err := runner.Run()
+	assert.Nil(t, err, "error running binary")
+	assert.Nil(t, <-runner.Errors(), "async error running binary")
+	assert.Contains(t, stdout.String(), "terminal")
+}
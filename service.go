@@ -0,0 +1,751 @@
+package gaper
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/fatih/color"
+)
+
+// ServiceConfig contains the settings for one of the programs supervised by gaper
+type ServiceConfig struct {
+	Name               string   `yaml:"name"`
+	BinName            string   `yaml:"bin_name"`
+	BuildPath          string   `yaml:"build_path"`
+	BuildArgs          []string `yaml:"-"`
+	BuildArgsMerged    string   `yaml:"build_args"`
+	ProgramArgs        []string `yaml:"-"`
+	ProgramArgsMerged  string   `yaml:"program_args"`
+	BuildCommand       []string `yaml:"-"`
+	BuildCommandMerged string   `yaml:"build_command"`
+	RunCommand         []string `yaml:"-"`
+	RunCommandMerged   string   `yaml:"run_command"`
+	ExecWrapper        []string `yaml:"-"`
+	ExecWrapperMerged  string   `yaml:"exec_wrapper"`
+	WatchItems         []string `yaml:"watch"`
+	IgnoreItems        []string `yaml:"ignore"`
+	NoRestartOn        string   `yaml:"no_restart_on"`
+	RestartPolicy      string   `yaml:"restart_policy"`
+	// services that must be started and ready before this one
+	DependsOn []string `yaml:"depends_on"`
+	// readiness probe used by the services depending on this one
+	// (e.g. "http://localhost:8080/health" or "tcp://localhost:5432")
+	Ready string `yaml:"ready"`
+	// time in ms to wait for the readiness probe to succeed
+	ReadyTimeout int `yaml:"ready_timeout"`
+	// restart this service whenever any of its dependencies restart
+	RestartWithDependencies bool `yaml:"restart_with_dependencies"`
+	// color used on the service name prefixing its output
+	OutputColor string `yaml:"output_color"`
+	// forwards the input read by gaper to the program, only
+	// a single service can have its input forwarded
+	Stdin bool `yaml:"stdin"`
+	// runs the program attached to a pseudo-terminal, which also forwards the input
+	PTY bool `yaml:"pty"`
+	// address the debugger of this service listens on in debug mode,
+	// by default the main debug address with its port increased by the service position
+	DebugAddr string `yaml:"debug_addr"`
+}
+
+// service is a program supervised by gaper
+type service struct {
+	name    string
+	builder Builder
+	runner  Runner
+	// decides what to do once the program exits on its own
+	restartPolicy RestartPolicy
+	// consecutive restarts caused by exits
+	exitRestarts int
+	// paths resolved from the service watch and ignore items,
+	// when watchPaths is nil the service reacts to any file change
+	watchPaths      map[string]bool
+	ignorePaths     map[string]bool
+	dependencies    []*service
+	restartWithDeps bool
+	ready           string
+	readyTimeout    time.Duration
+	// flag to know if an exit was caused by a restart from a file changing
+	changeRestart bool
+	// the program has been stopped by a command and must not be restarted
+	stopped bool
+	// current build and restart cycle and the one before it, which
+	// the exit of a program killed for a restart belongs to
+	cycle     *Cycle
+	prevCycle *Cycle
+	history   *History
+	events    *eventBus
+	// reports the diagnostics of the builds
+	diagnostics *diagnosticsReporter
+	// services restarted along with this one, which it waits to be ready before restarting
+	waitingFor map[string]bool
+	// posts to the run loop when the program is ready after a restart
+	notifier *readyNotifier
+}
+
+// serviceExit is an exit of the program supervised by a service
+type serviceExit struct {
+	service *service
+	err     error
+}
+
+// serviceReady is the readiness of the program started by a cycle of a service
+type serviceReady struct {
+	service *service
+	cycle   *Cycle
+}
+
+// readyNotifier posts the services which are ready after a restart to the run loop,
+// so waiting for them doesn't stop the loop from handling signals, commands and exits
+type readyNotifier struct {
+	ready chan serviceReady
+	done  chan struct{}
+}
+
+// newReadyNotifier creates a notifier shared by the services
+func newReadyNotifier(services []*service) *readyNotifier {
+	n := &readyNotifier{ready: make(chan serviceReady), done: make(chan struct{})}
+	for _, svc := range services {
+		svc.notifier = n
+	}
+
+	return n
+}
+
+// close stops the notifications still waiting for the services to be ready
+func (n *readyNotifier) close() {
+	close(n.done)
+}
+
+// debugAddr returns the address the debugger of a program listens on,
+// which is empty when the program doesn't run under the debugger
+func debugAddr(debug bool, addr string) string {
+	if !debug {
+		return ""
+	}
+
+	return addr
+}
+
+// newBuilder creates the builder of a program, a program with a run
+// command is only built when it also has a build command
+func newBuilder(runCommand []string, cfg BuilderConfig) Builder {
+	if len(runCommand) > 0 && len(cfg.Command) == 0 {
+		return &noBuilder{}
+	}
+
+	return NewBuilderWithConfig(cfg)
+}
+
+// programCommand returns the command running a program, which is the binary
+// built unless there is a run command, followed by the program args
+func programCommand(wd string, builder Builder, runCommand []string, programArgs []string) (string, []string) {
+	if len(runCommand) == 0 {
+		return filepath.Join(wd, builder.Binary()), programArgs
+	}
+
+	return runCommand[0], append(append([]string{}, runCommand[1:]...), programArgs...)
+}
+
+// programProcessGroup checks if a program runs in its own process group, which is always
+// the case for a run command since it may start the actual program as a child process
+// (e.g. "go run") that would otherwise be left behind when the command is killed
+func programProcessGroup(signalProcessGroup bool, runCommand []string) bool {
+	return signalProcessGroup || len(runCommand) > 0
+}
+
+// newServices creates the services supervised by gaper. In case there is
+// no service in the settings, the program from the main settings is used instead.
+func newServices(cfg *Config) ([]*service, error) {
+	diagnostics := newDiagnosticsReporter(cfg)
+	if len(cfg.Services) == 0 {
+		format, err := mainOutputFormat(cfg)
+		if err != nil {
+			return nil, err
+		}
+
+		policy, err := cfg.restartPolicy(nil)
+		if err != nil {
+			return nil, err
+		}
+
+		stdout, stderr := cfg.programOutput()
+		if format.enabled() {
+			stdout = newPrefixWriter(stdout, format, false)
+			stderr = newPrefixWriter(stderr, format, true)
+		}
+
+		builder := newBuilder(cfg.RunCommand, BuilderConfig{
+			Dir:              cfg.BuildPath,
+			BinName:          cfg.BinName,
+			WorkingDirectory: cfg.WorkingDirectory,
+			BuildArgs:        cfg.BuildArgs,
+			DisableWorkspace: cfg.DisableWorkspace,
+			Debug:            cfg.Debug,
+			Race:             cfg.Race,
+			Cover:            cfg.Cover,
+			Command:          cfg.BuildCommand,
+		})
+		bin, args := programCommand(cfg.WorkingDirectory, builder, cfg.RunCommand, cfg.ProgramArgs)
+		runner := NewRunnerWithConfig(RunnerConfig{
+			Stdout:       stdout,
+			Stderr:       stderr,
+			Bin:          bin,
+			Args:         args,
+			LogDir:       cfg.OutputLogDir,
+			LogRetention: cfg.OutputLogRetention,
+			PTY:          cfg.PTY,
+			ProcessGroup: programProcessGroup(cfg.SignalProcessGroup, cfg.RunCommand),
+			input:        cfg.programInput(cfg.Stdin || cfg.PTY),
+			DebugAddr:    debugAddr(cfg.Debug, cfg.DebugAddr),
+			CoverDir:     coverRunsPath(cfg),
+			Wrapper:      cfg.ExecWrapper,
+		})
+
+		return []*service{{
+			builder:       builder,
+			runner:        runner,
+			restartPolicy: policy,
+			history:       cfg.History,
+			events:        cfg.events,
+			diagnostics:   diagnostics,
+		}}, nil
+	}
+
+	extensions := cfg.Extensions
+	if len(extensions) == 0 {
+		extensions = DefaultExtensions
+	}
+	allowedExts := extensionsMap(extensions)
+
+	stdout, stderr := cfg.programOutput()
+
+	var services []*service
+	for i, svcCfg := range cfg.Services {
+		watchPaths, err := resolvePaths(mergeItems(svcCfg.WatchItems, cfg.WatchItems), allowedExts)
+		if err != nil {
+			return nil, err
+		}
+
+		ignorePaths, err := resolvePaths(mergeItems(svcCfg.IgnoreItems, cfg.IgnoreItems), allowedExts)
+		if err != nil {
+			return nil, err
+		}
+
+		policy, err := cfg.restartPolicy(&svcCfg)
+		if err != nil {
+			return nil, fmt.Errorf("%v on service \"%s\"", err, svcCfg.Name)
+		}
+
+		format := outputFormat{label: svcCfg.Name, color: outputColor(i), timestamp: cfg.OutputTimestamp}
+		if svcCfg.OutputColor != "" {
+			// already validated by setupServices
+			format.color, _ = parseOutputColor(svcCfg.OutputColor) // nolint errcheck
+		}
+
+		builder := newBuilder(svcCfg.RunCommand, BuilderConfig{
+			Name:             svcCfg.Name,
+			Dir:              svcCfg.BuildPath,
+			BinName:          svcCfg.BinName,
+			WorkingDirectory: cfg.WorkingDirectory,
+			BuildArgs:        svcCfg.BuildArgs,
+			DisableWorkspace: cfg.DisableWorkspace,
+			Debug:            cfg.Debug,
+			Race:             cfg.Race,
+			Cover:            cfg.Cover,
+			Command:          svcCfg.BuildCommand,
+		})
+		bin, args := programCommand(cfg.WorkingDirectory, builder, svcCfg.RunCommand, svcCfg.ProgramArgs)
+		runner := NewRunnerWithConfig(RunnerConfig{
+			Name:         svcCfg.Name,
+			Stdout:       newPrefixWriter(stdout, format, false),
+			Stderr:       newPrefixWriter(stderr, format, true),
+			Bin:          bin,
+			Args:         args,
+			LogDir:       serviceLogDir(cfg.OutputLogDir, svcCfg.Name),
+			LogRetention: cfg.OutputLogRetention,
+			PTY:          svcCfg.PTY,
+			ProcessGroup: programProcessGroup(cfg.SignalProcessGroup, svcCfg.RunCommand),
+			input:        cfg.programInput(svcCfg.Stdin || svcCfg.PTY),
+			DebugAddr:    debugAddr(cfg.Debug, svcCfg.DebugAddr),
+			CoverDir:     coverRunsPath(cfg),
+			Wrapper:      svcCfg.ExecWrapper,
+		})
+
+		logger.Debugf("Resolved %s watch paths: %v", programName(svcCfg.Name), watchPaths)
+		logger.Debugf("Resolved %s ignore paths: %v", programName(svcCfg.Name), ignorePaths)
+		services = append(services, &service{
+			name:          svcCfg.Name,
+			builder:       builder,
+			runner:        runner,
+			restartPolicy: policy,
+			watchPaths:    watchPaths,
+			ignorePaths:   ignorePaths,
+			// dependencies are resolved once all services are created
+			restartWithDeps: svcCfg.RestartWithDependencies,
+			ready:           svcCfg.Ready,
+			readyTimeout:    time.Duration(svcCfg.ReadyTimeout) * time.Millisecond,
+			history:         cfg.History,
+			events:          cfg.events,
+			diagnostics:     diagnostics,
+		})
+	}
+
+	byName := map[string]*service{}
+	for _, svc := range services {
+		byName[svc.name] = svc
+	}
+
+	for i, svcCfg := range cfg.Services {
+		for _, name := range svcCfg.DependsOn {
+			services[i].dependencies = append(services[i].dependencies, byName[name])
+		}
+	}
+
+	return sortServices(services)
+}
+
+// sortServices sorts the services so every service comes after its dependencies,
+// keeping the declaration order for services without dependencies between them
+func sortServices(services []*service) ([]*service, error) {
+	var sorted []*service
+	visited := map[*service]bool{}
+	visiting := map[*service]bool{}
+
+	var visit func(svc *service, path []string) error
+	visit = func(svc *service, path []string) error {
+		path = append(path, svc.name)
+		if visiting[svc] {
+			return fmt.Errorf("circular dependency between services: %s", strings.Join(path, " -> "))
+		}
+
+		if visited[svc] {
+			return nil
+		}
+
+		visiting[svc] = true
+		for _, dep := range svc.dependencies {
+			if err := visit(dep, path); err != nil {
+				return err
+			}
+		}
+		visiting[svc] = false
+
+		visited[svc] = true
+		sorted = append(sorted, svc)
+		return nil
+	}
+
+	for _, svc := range services {
+		if err := visit(svc, nil); err != nil {
+			return nil, err
+		}
+	}
+
+	return sorted, nil
+}
+
+// setupServices validates and fills the default values for the services settings
+func setupServices(cfg *Config) error {
+	var err error
+	names := map[string]bool{}
+
+	if cfg.Stdin || cfg.PTY {
+		return errors.New("stdin and pty must be set on a single service when there are services")
+	}
+
+	if len(cfg.BuildCommand) > 0 || len(cfg.RunCommand) > 0 {
+		return errors.New("build and run commands must be set on the services when there are services")
+	}
+
+	inputService := ""
+
+	for i := range cfg.Services {
+		svc := &cfg.Services[i]
+
+		if svc.Name == "" {
+			return errors.New("service name is required")
+		}
+
+		if names[svc.Name] {
+			return fmt.Errorf("duplicated service name \"%s\"", svc.Name)
+		}
+		names[svc.Name] = true
+
+		if svc.Stdin || svc.PTY {
+			if inputService != "" {
+				return fmt.Errorf("stdin or pty set on services \"%s\" and \"%s\", "+
+					"the input can only be forwarded to a single service", inputService, svc.Name)
+			}
+			inputService = svc.Name
+		}
+
+		if len(svc.BuildPath) == 0 {
+			svc.BuildPath = DefaultBuildPath
+		}
+
+		// use the service name so the binaries do not override each other
+		if svc.BinName == "" {
+			svc.BinName = svc.Name
+		}
+
+		// the main restart settings are used unless the service has its own
+		if _, err := cfg.restartPolicy(svc); err != nil {
+			return fmt.Errorf("%v on service \"%s\"", err, svc.Name)
+		}
+
+		svc.BuildArgs, err = parseInnerArgs(svc.BuildArgs, svc.BuildArgsMerged)
+		if err != nil {
+			return err
+		}
+
+		svc.ProgramArgs, err = parseInnerArgs(svc.ProgramArgs, svc.ProgramArgsMerged)
+		if err != nil {
+			return err
+		}
+
+		svc.ExecWrapper, err = parseInnerArgs(svc.ExecWrapper, svc.ExecWrapperMerged)
+		if err != nil {
+			return err
+		}
+
+		svc.BuildCommand, err = parseInnerArgs(svc.BuildCommand, svc.BuildCommandMerged)
+		if err != nil {
+			return err
+		}
+
+		svc.RunCommand, err = parseInnerArgs(svc.RunCommand, svc.RunCommandMerged)
+		if err != nil {
+			return err
+		}
+
+		if cfg.Debug && len(svc.RunCommand) > 0 {
+			return fmt.Errorf("%v on service \"%s\"", errDebugRunCommand, svc.Name)
+		}
+
+		// the main wrapper is used unless the service has its own
+		if len(svc.ExecWrapper) == 0 {
+			svc.ExecWrapper = cfg.ExecWrapper
+		}
+
+		if svc.Ready != "" {
+			if err := validateReadyProbe(svc.Ready); err != nil {
+				return fmt.Errorf("%v on service \"%s\"", err, svc.Name)
+			}
+		}
+
+		if svc.OutputColor != "" {
+			if _, err := parseOutputColor(svc.OutputColor); err != nil {
+				return fmt.Errorf("%v on service \"%s\"", err, svc.Name)
+			}
+		}
+
+		if svc.ReadyTimeout == 0 {
+			svc.ReadyTimeout = DefaultReadyTimeout
+		}
+
+		if cfg.Debug {
+			addr, index := svc.DebugAddr, 0
+			if addr == "" {
+				addr, index = cfg.DebugAddr, i
+			}
+
+			if svc.DebugAddr, err = serviceDebugAddr(addr, index); err != nil {
+				return fmt.Errorf("%v on service \"%s\"", err, svc.Name)
+			}
+		}
+
+		if len(svc.WatchItems) == 0 {
+			svc.WatchItems = append(svc.WatchItems, svc.BuildPath)
+		}
+
+		moduleDirs, err := localModuleDirs(svc.BuildPath, !cfg.DisableWorkspace)
+		if err != nil {
+			return err
+		}
+
+		for _, dir := range moduleDirs {
+			svc.WatchItems = append(svc.WatchItems, relativePath(cfg.WorkingDirectory, dir))
+		}
+	}
+
+	// dependencies are validated once all names are known
+	for _, svc := range cfg.Services {
+		for _, dep := range svc.DependsOn {
+			if dep == svc.Name {
+				return fmt.Errorf("service \"%s\" can't depend on itself", svc.Name)
+			}
+
+			if !names[dep] {
+				return fmt.Errorf("service \"%s\" depends on unknown service \"%s\"", svc.Name, dep)
+			}
+		}
+	}
+
+	return nil
+}
+
+// mainOutputFormat resolves the output format for the program from the main settings
+func mainOutputFormat(cfg *Config) (outputFormat, error) {
+	format := outputFormat{label: cfg.OutputPrefix, color: color.FgGreen, timestamp: cfg.OutputTimestamp}
+	if cfg.OutputColor == "" {
+		return format, nil
+	}
+
+	var err error
+	format.color, err = parseOutputColor(cfg.OutputColor)
+	return format, err
+}
+
+// serviceLogDir resolves the directory for the output logs of a service
+func serviceLogDir(dir string, name string) string {
+	if dir == "" {
+		return ""
+	}
+
+	return filepath.Join(dir, name)
+}
+
+// watchItems returns the items watched by all the services
+func watchItems(cfg *Config) []string {
+	if len(cfg.Services) == 0 {
+		return cfg.WatchItems
+	}
+
+	items := cfg.WatchItems
+	for _, svc := range cfg.Services {
+		items = mergeItems(items, svc.WatchItems)
+	}
+
+	return items
+}
+
+// watches checks if a changed file is watched by this service
+func (s *service) watches(file string) bool {
+	if s.watchPaths == nil {
+		return true
+	}
+
+	for path := range s.ignorePaths {
+		if isSubPath(path, file) {
+			return false
+		}
+	}
+
+	for path := range s.watchPaths {
+		if isSubPath(path, file) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// watchesAny checks if any of the changed files is watched by this service
+func (s *service) watchesAny(files []string) bool {
+	for _, file := range files {
+		if s.watches(file) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// watchedFiles filters the changed files watched by this service
+func (s *service) watchedFiles(files []string) []string {
+	var watched []string
+	for _, file := range files {
+		if s.watches(file) {
+			watched = append(watched, file)
+		}
+	}
+
+	return watched
+}
+
+// dependsOn checks if this service depends directly on the given service
+func (s *service) dependsOn(name string) bool {
+	for _, dep := range s.dependencies {
+		if dep.name == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+// waitDependencies waits for the dependencies of this service to be ready
+func (s *service) waitDependencies() {
+	for _, dep := range s.dependencies {
+		dep.waitReady()
+	}
+}
+
+// waitReady waits for the service readiness probe to succeed. A failure
+// is only logged so the services depending on it are started anyway.
+func (s *service) waitReady() {
+	if s.ready == "" {
+		return
+	}
+
+	logger.Info("Waiting for " + programName(s.name) + " to be ready")
+	if err := waitReady(s.ready, s.readyTimeout); err != nil {
+		logger.Warnf("Readiness probe failed for %s: %v", programName(s.name), err)
+	}
+}
+
+// notifyReady waits in the background for the program started by the current cycle
+// to be ready and then posts it to the run loop
+func (s *service) notifyReady() {
+	ready := serviceReady{service: s, cycle: s.cycle}
+	go func() {
+		s.waitReady()
+
+		select {
+		case s.notifier.ready <- ready:
+		case <-s.notifier.done:
+		}
+	}()
+}
+
+// stop kills the program and keeps it stopped until it is started by a command
+func (s *service) stop() error {
+	s.stopped = true
+	if !s.runner.IsRunning() || s.runner.Exited() {
+		return nil
+	}
+
+	return s.runner.Kill()
+}
+
+// startCycle begins a new build and restart cycle for the service
+func (s *service) startCycle(trigger string, files []string) *Cycle {
+	// only consecutive restarts caused by exits are counted
+	switch trigger {
+	case CycleTriggerExit:
+		s.exitRestarts++
+	case CycleTriggerChange, CycleTriggerCommand:
+		s.exitRestarts = 0
+	}
+
+	s.prevCycle = s.cycle
+	s.cycle = newCycle(s.name, trigger, files)
+	s.cycle.events = s.events
+	s.cycle.diagnostics = s.diagnostics
+	return s.cycle
+}
+
+// finishCycle logs the summary of the current cycle once the program is running
+// and keeps it in the history. The time until the program is ready is measured
+// in the background when the service has a readiness probe.
+func (s *service) finishCycle() {
+	c := s.cycle
+	if c == nil {
+		return
+	}
+
+	s.history.add(c)
+	if c.BuildError != nil || c.ProgramStartedAt.IsZero() {
+		return
+	}
+
+	s.events.publish(Event{Type: EventStart, Service: s.name, PID: c.PID, Summary: c.Summary()})
+
+	logger.InfoWith(capitalize(programName(s.name))+" "+c.Summary(),
+		Field("trigger", c.Trigger),
+		Field("build_duration", c.BuildDuration.Round(time.Millisecond)),
+		Field("duration", c.Duration.Round(time.Millisecond)))
+
+	if s.ready == "" {
+		return
+	}
+
+	probe, timeout, history := s.ready, s.readyTimeout, s.history
+	go func() {
+		if err := waitReady(probe, timeout); err != nil {
+			return
+		}
+
+		var readyDuration time.Duration
+		history.update(c, func(c *Cycle) {
+			c.ReadyDuration = time.Since(c.ProgramStartedAt)
+			readyDuration = c.ReadyDuration
+		})
+		logger.DebugWith("Detected "+programName(s.name)+" ready",
+			Field("ready_duration", readyDuration.Round(time.Millisecond)))
+	}()
+}
+
+// recordExit records the exit of the program started by the given cycle
+func (s *service) recordExit(c *Cycle, exit ExitInfo) {
+	s.events.publish(Event{Type: EventExit, Service: s.name, ExitCode: exit.ExitCode, Summary: exit.Summary()})
+	s.history.addExit(exit)
+	if c == nil {
+		return
+	}
+
+	s.history.update(c, func(c *Cycle) {
+		c.Exited = true
+		c.ExitCode = exit.ExitCode
+		c.Exit = exit
+		c.ExitedAt = time.Now()
+	})
+}
+
+// exitInfo describes the exit of the program started by the given cycle
+func (s *service) exitInfo(err error, c *Cycle) ExitInfo {
+	exit := ExitInfo{
+		Service:  s.name,
+		ExitCode: s.runner.ExitStatus(err),
+		Err:      err,
+		Restarts: s.exitRestarts,
+	}
+
+	if c != nil && !c.ProgramStartedAt.IsZero() {
+		exit.Runtime = time.Since(c.ProgramStartedAt)
+	}
+
+	exit.Signal, _, exit.CoreDump = exitSignal(err)
+	if inspector, ok := s.runner.(exitInspector); ok {
+		inspector.inspectExit(err, &exit)
+	}
+
+	return exit
+}
+
+// wrapError adds the context and the service name to an error
+func (s *service) wrapError(context string, err error) error {
+	if s.name == "" {
+		return fmt.Errorf("%s: %v", context, err)
+	}
+
+	return fmt.Errorf("%s on %s: %v", context, s.name, err)
+}
+
+// programName resolves how a program is referred to in the logs
+func programName(name string) string {
+	if name == "" {
+		return "program"
+	}
+
+	return "program " + name
+}
+
+// capitalize makes the first letter of a log message upper case
+func capitalize(msg string) string {
+	if msg == "" {
+		return msg
+	}
+
+	return strings.ToUpper(msg[:1]) + msg[1:]
+}
+
+// mergeItems returns a new list with the items of both lists
+func mergeItems(a []string, b []string) []string {
+	result := make([]string, 0, len(a)+len(b))
+	result = append(result, a...)
+	return append(result, b...)
+}
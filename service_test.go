@@ -0,0 +1,243 @@
+package gaper
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestServiceSetupDefaults(t *testing.T) {
+	cfg := &Config{
+		NoRestartOn: NoRestartOnExit,
+		ExecWrapper: []string{"time"},
+		Services: []ServiceConfig{
+			{Name: "api", ProgramArgsMerged: "-port 8080"},
+		},
+	}
+
+	err := setupServices(cfg)
+	assert.Nil(t, err, "setup error")
+
+	svc := cfg.Services[0]
+	assert.Equal(t, DefaultBuildPath, svc.BuildPath)
+	assert.Equal(t, "api", svc.BinName)
+	policy, err := cfg.restartPolicy(&svc)
+	assert.Nil(t, err, "policy error")
+	assert.Equal(t, NeverRestart{}, policy)
+	assert.Equal(t, []string{"-port", "8080"}, svc.ProgramArgs)
+	assert.Equal(t, []string{"time"}, svc.ExecWrapper)
+	assert.Equal(t, []string{DefaultBuildPath}, svc.WatchItems)
+}
+
+func TestServiceSetupErrors(t *testing.T) {
+	testCases := []struct {
+		name     string
+		services []ServiceConfig
+		debug    bool
+		err      string
+	}{
+		{
+			name:     "missing name",
+			services: []ServiceConfig{{BuildPath: "."}},
+			err:      "service name is required",
+		},
+		{
+			name:     "duplicated name",
+			services: []ServiceConfig{{Name: "api"}, {Name: "api"}},
+			err:      "duplicated service name \"api\"",
+		},
+		{
+			name:     "unknown dependency",
+			services: []ServiceConfig{{Name: "worker", DependsOn: []string{"api"}}},
+			err:      "service \"worker\" depends on unknown service \"api\"",
+		},
+		{
+			name:     "self dependency",
+			services: []ServiceConfig{{Name: "api", DependsOn: []string{"api"}}},
+			err:      "service \"api\" can't depend on itself",
+		},
+		{
+			name:     "invalid readiness probe",
+			services: []ServiceConfig{{Name: "api", Ready: "localhost:8080"}},
+			err: "invalid readiness probe \"localhost:8080\": it must start with " +
+				"\"http://\", \"https://\" or \"tcp://\" on service \"api\"",
+		},
+		{
+			name:     "input forwarded to multiple services",
+			services: []ServiceConfig{{Name: "api", Stdin: true}, {Name: "cli", PTY: true}},
+			err: "stdin or pty set on services \"api\" and \"cli\", " +
+				"the input can only be forwarded to a single service",
+		},
+		{
+			name:     "debug with a run command",
+			services: []ServiceConfig{{Name: "sidecar", RunCommandMerged: "python sidecar.py"}},
+			debug:    true,
+			err: "debug mode requires the programs to be built and run by gaper, " +
+				"it can't be used with a run command on service \"sidecar\"",
+		},
+		{
+			name:     "invalid program args",
+			services: []ServiceConfig{{Name: "api", ProgramArgsMerged: "foo '"}},
+			err:      "invalid command line string",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := setupServices(&Config{Services: tc.services, Debug: tc.debug})
+			assert.NotNil(t, err, "setup error")
+			assert.Equal(t, tc.err, err.Error())
+		})
+	}
+}
+
+func TestServiceSetupMainRunCommand(t *testing.T) {
+	cfg := &Config{RunCommand: []string{"python", "app.py"}, Services: []ServiceConfig{{Name: "api"}}}
+	err := setupServices(cfg)
+	assert.NotNil(t, err, "setup error")
+	assert.Equal(t, "build and run commands must be set on the services when there are services", err.Error())
+}
+
+func TestServiceProgramCommand(t *testing.T) {
+	builder := newBuilder([]string{"python", "app.py"}, BuilderConfig{BinName: "app"})
+	assert.IsType(t, &noBuilder{}, builder)
+
+	bin, args := programCommand("/src", builder, []string{"python", "app.py"}, []string{"-v"})
+	assert.Equal(t, "python", bin)
+	assert.Equal(t, []string{"app.py", "-v"}, args)
+
+	builder = newBuilder([]string{"./bin/app"}, BuilderConfig{BinName: "app", Command: []string{"make"}})
+	assert.IsType(t, &commandBuilder{}, builder)
+
+	builder = newBuilder(nil, BuilderConfig{BinName: "app", Command: []string{"make"}})
+	bin, args = programCommand("/src", builder, nil, []string{"-v"})
+	assert.Equal(t, filepath.Join("/src", resolveBinNameByOS("app")), bin)
+	assert.Equal(t, []string{"-v"}, args)
+}
+
+func TestServiceRunCommandKill(t *testing.T) {
+	if runtime.GOOS == OSWindows {
+		t.Skip("sh is not available on windows")
+	}
+
+	// the shell only handles the interrupt once its child ends, as "go run" does,
+	// so the child must be interrupted too to stop the command without a hard kill
+	cfg := &Config{RunCommand: []string{"sh", "-c", `trap "exit 1" INT; sleep 30`}}
+	cfg.stdout, cfg.stderr = ioutil.Discard, ioutil.Discard
+	services, err := newServices(cfg)
+	assert.Nil(t, err, "services error")
+
+	runner := services[0].runner
+	_, err = runner.Run()
+	assert.Nil(t, err, "error running command")
+
+	errs := make(chan error, 1)
+	go func() {
+		errs <- <-runner.Errors()
+	}()
+	time.Sleep(200 * time.Millisecond)
+
+	start := time.Now()
+	assert.Nil(t, runner.Kill(), "error killing command")
+	assert.True(t, time.Since(start) < 3*time.Second, "command killed without a hard kill")
+	assert.NotNil(t, <-errs, "kill command")
+}
+
+func TestServiceRestartPolicy(t *testing.T) {
+	cfg := &Config{
+		RestartPolicy: RestartPolicyOnFailure,
+		CustomRestartPolicy: RestartPolicyFunc(func(exit ExitInfo) RestartDecision {
+			return RestartQuit
+		}),
+		Services: []ServiceConfig{
+			{Name: "api", RestartPolicy: RestartPolicyNever},
+			{Name: "worker", NoRestartOn: NoRestartOnSuccess},
+			{Name: "scheduler"},
+		},
+	}
+
+	assert.Nil(t, setupServices(cfg), "setup error")
+	services, err := newServices(cfg)
+	assert.Nil(t, err, "services error")
+
+	// the restart settings of the services have priority over the custom policy
+	assert.Equal(t, NeverRestart{}, services[0].restartPolicy)
+	assert.Equal(t, OnFailureRestart{}, services[1].restartPolicy)
+	assert.Equal(t, RestartQuit, services[2].restartPolicy.Decide(ExitInfo{}))
+}
+
+func TestServiceWatches(t *testing.T) {
+	cfg := &Config{
+		IgnoreItems: []string{filepath.Join("testdata", "server", "main_test.go")},
+		Services: []ServiceConfig{
+			{Name: "api", WatchItems: []string{filepath.Join("testdata", "server")}},
+			{Name: "worker", WatchItems: []string{filepath.Join("testdata", "modules")}},
+		},
+	}
+
+	services, err := newServices(cfg)
+	assert.Nil(t, err, "services error")
+
+	api, worker := services[0], services[1]
+	mainfile := filepath.Join("testdata", "server", "main.go")
+	testfile := filepath.Join("testdata", "server", "main_test.go")
+	libfile := filepath.Join("testdata", "modules", "lib", "lib.go")
+
+	assert.True(t, api.watches(mainfile))
+	assert.False(t, api.watches(testfile))
+	assert.False(t, api.watches(libfile))
+	assert.False(t, worker.watches(mainfile))
+	assert.True(t, worker.watches(libfile))
+	assert.True(t, worker.watchesAny([]string{mainfile, libfile}))
+}
+
+func TestServiceWatchItems(t *testing.T) {
+	cfg := &Config{
+		WatchItems: []string{"shared"},
+		Services: []ServiceConfig{
+			{Name: "api", WatchItems: []string{"api"}},
+			{Name: "worker", WatchItems: []string{"worker"}},
+		},
+	}
+
+	assert.Equal(t, []string{"shared", "api", "worker"}, watchItems(cfg))
+}
+
+func TestServiceSortByDependencies(t *testing.T) {
+	cfg := &Config{
+		Services: []ServiceConfig{
+			{Name: "scheduler", DependsOn: []string{"worker"}},
+			{Name: "worker", DependsOn: []string{"api"}},
+			{Name: "api"},
+			{Name: "docs"},
+		},
+	}
+
+	services, err := newServices(cfg)
+	assert.Nil(t, err, "services error")
+
+	var names []string
+	for _, svc := range services {
+		names = append(names, svc.name)
+	}
+	assert.Equal(t, []string{"api", "worker", "scheduler", "docs"}, names)
+	assert.True(t, services[1].dependsOn("api"))
+	assert.False(t, services[1].dependsOn("scheduler"))
+}
+
+func TestServiceSortCircularDependency(t *testing.T) {
+	cfg := &Config{
+		Services: []ServiceConfig{
+			{Name: "api", DependsOn: []string{"worker"}},
+			{Name: "worker", DependsOn: []string{"api"}},
+		},
+	}
+
+	_, err := newServices(cfg)
+	assert.NotNil(t, err, "services error")
+	assert.Equal(t, "circular dependency between services: api -> worker -> api", err.Error())
+}
@@ -0,0 +1,64 @@
+package gaper
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// parseSignals converts the names of the signals forwarded to the programs
+// (e.g. "SIGUSR1" or "usr1") to the signals
+func parseSignals(names []string) ([]os.Signal, error) {
+	var signals []os.Signal
+	for _, name := range names {
+		key := strings.TrimPrefix(strings.ToUpper(strings.TrimSpace(name)), "SIG")
+		sig, ok := forwardableSignals[key]
+		if !ok {
+			return nil, fmt.Errorf("invalid forwarded signal \"%s\", supported signals: %s",
+				name, strings.Join(forwardableSignalNames(), ", "))
+		}
+
+		signals = append(signals, sig)
+	}
+
+	return signals, nil
+}
+
+// forwardableSignalNames lists the names of the signals that can be forwarded
+func forwardableSignalNames() []string {
+	var names []string
+	for name := range forwardableSignals {
+		names = append(names, "SIG"+name)
+	}
+	sort.Strings(names)
+
+	if len(names) == 0 {
+		return []string{"none on " + OSWindows}
+	}
+
+	return names
+}
+
+// containsSignal checks if the signal is in the list
+func containsSignal(signals []os.Signal, sig os.Signal) bool {
+	for _, s := range signals {
+		if s == sig {
+			return true
+		}
+	}
+
+	return false
+}
+
+// withoutSignals removes the excluded signals from the list
+func withoutSignals(signals []os.Signal, excluded []os.Signal) []os.Signal {
+	var result []os.Signal
+	for _, sig := range signals {
+		if !containsSignal(excluded, sig) {
+			result = append(result, sig)
+		}
+	}
+
+	return result
+}
@@ -0,0 +1,16 @@
+package gaper
+
+import (
+	"os"
+	"syscall"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWithoutSignals(t *testing.T) {
+	signals := []os.Signal{syscall.SIGINT, syscall.SIGTERM}
+	assert.Equal(t, []os.Signal{syscall.SIGTERM}, withoutSignals(signals, []os.Signal{syscall.SIGINT}))
+	assert.Equal(t, signals, withoutSignals(signals, nil))
+	assert.Nil(t, withoutSignals(signals, signals))
+}
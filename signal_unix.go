@@ -0,0 +1,56 @@
+//go:build !windows
+// +build !windows
+
+package gaper
+
+import (
+	"os"
+	"os/exec"
+	"syscall"
+)
+
+// signals toggling the pause of the watching
+var pauseSignals = []os.Signal{syscall.SIGUSR1}
+
+// signal forcing a rebuild and restart of the programs when enabled
+var restartSignal os.Signal = syscall.SIGHUP
+
+// signals that can be forwarded to the programs, the ones used by gaper
+// to stop (e.g. SIGINT and SIGTERM) are not included
+var forwardableSignals = map[string]os.Signal{
+	"HUP":   syscall.SIGHUP,
+	"QUIT":  syscall.SIGQUIT,
+	"USR1":  syscall.SIGUSR1,
+	"USR2":  syscall.SIGUSR2,
+	"ALRM":  syscall.SIGALRM,
+	"WINCH": syscall.SIGWINCH,
+	"CONT":  syscall.SIGCONT,
+	"TSTP":  syscall.SIGTSTP,
+	"TTIN":  syscall.SIGTTIN,
+	"TTOU":  syscall.SIGTTOU,
+	"PROF":  syscall.SIGPROF,
+	"URG":   syscall.SIGURG,
+}
+
+// setProcessGroup makes the command start in its own process group
+func setProcessGroup(cmd *exec.Cmd) {
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.Setpgid = true
+}
+
+// signalProcessGroup sends the signal to every process in the group of the process
+func signalProcessGroup(process *os.Process, sig os.Signal) error {
+	s, ok := sig.(syscall.Signal)
+	if !ok {
+		return process.Signal(sig)
+	}
+
+	// there is no process left in the group
+	if err := syscall.Kill(-process.Pid, s); err != nil && err != syscall.ESRCH {
+		return err
+	}
+
+	return nil
+}
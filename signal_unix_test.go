@@ -0,0 +1,94 @@
+//go:build !windows
+// +build !windows
+
+package gaper
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseSignals(t *testing.T) {
+	signals, err := parseSignals([]string{"SIGUSR1", "usr2", " HUP "})
+	assert.Nil(t, err, "valid signals")
+	assert.Equal(t, []os.Signal{syscall.SIGUSR1, syscall.SIGUSR2, syscall.SIGHUP}, signals)
+
+	_, err = parseSignals([]string{"SIGTERM"})
+	assert.NotNil(t, err, "signal used by gaper")
+	assert.Contains(t, err.Error(), "invalid forwarded signal \"SIGTERM\", supported signals: SIGALRM")
+
+	_, err = parseSignals([]string{"SIGFOO"})
+	assert.NotNil(t, err, "unknown signal")
+}
+
+func TestSetupConfigRestartSignalForwarded(t *testing.T) {
+	cfg := &Config{ForwardSignals: []string{"SIGHUP"}, RestartOnSIGHUP: true}
+	err := setupConfig(cfg)
+	assert.NotNil(t, err, "setup error")
+	assert.Equal(t, "SIGHUP can't be forwarded to the programs while it restarts them", err.Error())
+}
+
+func TestRunnerSignal(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gaper-signal")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir) // nolint errcheck
+
+	testCases := []struct {
+		name         string
+		script       string
+		processGroup bool
+		expected     string
+	}{
+		{
+			name:     "process",
+			script:   `trap 'echo process; exit 0' USR1; touch "$1"; while :; do sleep 0.1; done`,
+			expected: "process\n",
+		},
+		{
+			// the signal only reaches the nested shell when sent to the process group
+			name: "process group",
+			script: `trap 'wait; exit 0' USR1
+sh -c 'trap "echo nested; exit 0" USR1; touch "$1"; while :; do sleep 0.1; done' nested "$1" &
+while :; do sleep 0.1; done`,
+			processGroup: true,
+			expected:     "nested\n",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ready := filepath.Join(dir, tc.name)
+			stdout := bytes.NewBufferString("")
+			runner := NewRunnerWithConfig(RunnerConfig{
+				Stdout:       stdout,
+				Stderr:       ioutil.Discard,
+				Bin:          "sh",
+				Args:         []string{"-c", tc.script, "gaper", ready},
+				ProcessGroup: tc.processGroup,
+			})
+
+			_, err := runner.Run()
+			assert.Nil(t, err, "error running binary")
+
+			// wait for the traps to be set
+			for i := 0; i < 100; i++ {
+				if _, err := os.Stat(ready); err == nil {
+					break
+				}
+				time.Sleep(50 * time.Millisecond)
+			}
+			assert.Nil(t, runner.Signal(syscall.SIGUSR1), "error sending signal")
+			assert.Nil(t, <-runner.Errors(), "async error running binary")
+			assert.Equal(t, tc.expected, stdout.String())
+		})
+	}
+}
@@ -0,0 +1,23 @@
+package gaper
+
+import (
+	"os"
+	"os/exec"
+)
+
+// signals toggling the pause of the watching, there is none on Windows
+var pauseSignals []os.Signal
+
+// signal forcing a rebuild and restart of the programs, there is none on Windows
+var restartSignal os.Signal
+
+// signals that can be forwarded to the programs, there is none on Windows
+var forwardableSignals = map[string]os.Signal{}
+
+// setProcessGroup does nothing on Windows
+func setProcessGroup(cmd *exec.Cmd) {}
+
+// signalProcessGroup sends the signal only to the process on Windows
+func signalProcessGroup(process *os.Process, sig os.Signal) error {
+	return process.Signal(sig)
+}
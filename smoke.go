@@ -0,0 +1,240 @@
+package gaper
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// errSmokeCommand is returned when there is no command to run on a smoke test
+var errSmokeCommand = errors.New("smoke command is required")
+
+// SmokeConfig defines the settings of a smoke test
+type SmokeConfig struct {
+	// readiness probe checked before running the command, in the same
+	// formats of the services probes (e.g. "http://localhost:8080/health")
+	Ready string
+	// time in ms to wait for the programs to be ready
+	ReadyTimeout int
+	// command run against the programs once they are ready
+	Command []string
+}
+
+// CommandExitError is returned when the command of a smoke test fails,
+// so gaper can exit with the same code
+type CommandExitError struct {
+	Command []string
+	Exit    ExitInfo
+}
+
+// Error describes the command exit
+func (e *CommandExitError) Error() string {
+	return "smoke command " + e.Exit.Summary()
+}
+
+// ExitCode returns the exit code gaper exits with
+func (e *CommandExitError) ExitCode() int {
+	return e.Exit.shellExitCode()
+}
+
+// maxSmokeOutput is the max size of the programs output kept during a smoke test,
+// only the end of a longer output is kept
+var maxSmokeOutput = 1024 * 1024
+
+// smokeOutput keeps the programs output during a smoke test
+type smokeOutput struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+	// the beginning of the output has been dropped
+	truncated bool
+}
+
+// Write appends the output to the buffer, dropping the beginning
+// of the output once it is longer than maxSmokeOutput
+func (o *smokeOutput) Write(data []byte) (int, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	n, err := o.buf.Write(data)
+	if extra := o.buf.Len() - maxSmokeOutput; extra > 0 {
+		o.buf.Next(extra)
+		o.truncated = true
+	}
+
+	return n, err
+}
+
+// dump writes the output kept so far
+func (o *smokeOutput) dump(w io.Writer) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if o.buf.Len() == 0 {
+		logger.Info("No output from the programs")
+		return
+	}
+
+	if o.truncated {
+		logger.Info(fmt.Sprintf("Last %d bytes of output from the programs:", o.buf.Len()))
+	} else {
+		logger.Info("Output from the programs:")
+	}
+	w.Write(o.buf.Bytes()) // nolint errcheck
+}
+
+// Smoke builds and runs the programs from the config, waits for them to be ready and
+// runs the command against them. The programs are stopped once the command finishes
+// and their output, kept apart from the command output, is written to stderr in case
+// of failure. A CommandExitError is returned when the command fails.
+func Smoke(cfg *Config, smokeCfg SmokeConfig, chOSSiginal chan os.Signal) error {
+	logger.Debug("Starting gaper smoke test")
+
+	if len(smokeCfg.Command) == 0 {
+		return errSmokeCommand
+	}
+
+	if smokeCfg.Ready != "" {
+		if err := validateReadyProbe(smokeCfg.Ready); err != nil {
+			return err
+		}
+	}
+
+	if smokeCfg.ReadyTimeout == 0 {
+		smokeCfg.ReadyTimeout = DefaultReadyTimeout
+	}
+
+	// listen for OS signals
+	signal.Notify(chOSSiginal, os.Interrupt, syscall.SIGTERM)
+
+	output := &smokeOutput{}
+	cfg.stdout, cfg.stderr = output, output
+	if err := setupConfig(cfg); err != nil {
+		return err
+	}
+
+	logger.Debugf("Config: %+v", cfg)
+
+	if cfg.Cover {
+		if err := resetCoverage(cfg.CoverDir); err != nil {
+			return err
+		}
+	}
+
+	services, err := newServices(cfg)
+	if err != nil {
+		return err
+	}
+
+	err = runSmoke(services, smokeCfg, chOSSiginal)
+	if errStop := stopServices(services); errStop != nil {
+		logger.Error("Error stopping:", errStop)
+	}
+
+	if cfg.Cover {
+		if errCover := mergeCoverage(cfg.CoverDir, cfg.WorkingDirectory); errCover != nil {
+			logger.Error("Error merging coverage:", errCover)
+		}
+	}
+
+	if err != nil {
+		output.dump(os.Stderr)
+	}
+
+	return err
+}
+
+// runSmoke runs the programs and the smoke command, returning the command result
+func runSmoke(services []*service, smokeCfg SmokeConfig, chOSSiginal chan os.Signal) error { // nolint gocyclo
+	if err := launchServices(services); err != nil {
+		return err
+	}
+
+	exits := mergeExits(services)
+	timeout := time.Duration(smokeCfg.ReadyTimeout) * time.Millisecond
+
+	ready := make(chan error, 1)
+	go func() {
+		ready <- waitSmokeReady(services, smokeCfg.Ready, timeout)
+	}()
+
+	select {
+	case err := <-ready:
+		if err != nil {
+			return err
+		}
+	case exit := <-exits:
+		info := exit.service.exitInfo(exit.err, exit.service.cycle)
+		return fmt.Errorf("%s before being ready", programName(exit.service.name)+" "+info.Summary())
+	case signal := <-chOSSiginal:
+		return fmt.Errorf("OS signal: %v", signal)
+	}
+
+	logger.Info("Running smoke command:", strings.Join(smokeCfg.Command, " "))
+	cmd := exec.Command(smokeCfg.Command[0], smokeCfg.Command[1:]...) // nolint gas
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("smoke command error: %v", err)
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- cmd.Wait()
+	}()
+
+	for {
+		select {
+		case err := <-done:
+			if err == nil {
+				logger.Info("Smoke command succeeded")
+				return nil
+			}
+
+			exit := ExitInfo{ExitCode: exitStatus(err), Err: err}
+			exit.Signal, _, exit.CoreDump = exitSignal(err)
+			logger.ErrorWith("Smoke command "+exit.Summary(), exit.fields()...)
+			return &CommandExitError{Command: smokeCfg.Command, Exit: exit}
+		case exit := <-exits:
+			// the command decides whether the exit is a failure
+			info := exit.service.exitInfo(exit.err, exit.service.cycle)
+			logger.WarnWith(capitalize(programName(exit.service.name))+" "+info.Summary()+" while running the smoke command", info.fields()...)
+		case signal := <-chOSSiginal:
+			cmd.Process.Kill() // nolint errcheck
+			return fmt.Errorf("OS signal: %v", signal)
+		}
+	}
+}
+
+// waitSmokeReady waits for the readiness probes of the services and the smoke test
+func waitSmokeReady(services []*service, probe string, timeout time.Duration) error {
+	for _, svc := range services {
+		if svc.ready == "" {
+			continue
+		}
+
+		logger.Info("Waiting for " + programName(svc.name) + " to be ready")
+		if err := waitReady(svc.ready, svc.readyTimeout); err != nil {
+			return svc.wrapError("ready error", err)
+		}
+	}
+
+	if probe == "" {
+		return nil
+	}
+
+	logger.Info("Waiting for the programs to be ready")
+	if err := waitReady(probe, timeout); err != nil {
+		return fmt.Errorf("ready error: %v", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,128 @@
+package gaper
+
+import (
+	"bytes"
+	"errors"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/maxcnunes/gaper/testdata"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSmoke(t *testing.T) {
+	if runtime.GOOS == OSWindows {
+		t.Skip("sh is not available on windows")
+	}
+
+	testCases := []struct {
+		name     string
+		command  []string
+		exitCode int
+	}{
+		{
+			name:     "command succeeds",
+			command:  []string{"sh", "-c", "exit 0"},
+			exitCode: 0,
+		},
+		{
+			name:     "command fails",
+			command:  []string{"sh", "-c", "exit 3"},
+			exitCode: 3,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			addr := freeAddr(t)
+			cfg := &Config{BuildPath: filepath.Join("testdata", "server"), ProgramArgs: []string{"-addr", addr}}
+			smokeCfg := SmokeConfig{Ready: "tcp://" + addr, Command: tc.command}
+
+			err := Smoke(cfg, smokeCfg, make(chan os.Signal, 2))
+			if tc.exitCode == 0 {
+				assert.Nil(t, err, "smoke error")
+				return
+			}
+
+			exitErr, ok := err.(*CommandExitError)
+			assert.True(t, ok, "command exit error")
+			assert.Equal(t, tc.exitCode, exitErr.ExitCode())
+			assert.Equal(t, "smoke command exited with code 3", exitErr.Error())
+		})
+	}
+}
+
+// freeAddr returns a localhost address with a port not in use
+func freeAddr(t *testing.T) string {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err, "listen error")
+	defer listener.Close() // nolint errcheck
+
+	return listener.Addr().String()
+}
+
+func TestSmokeInvalidConfig(t *testing.T) {
+	err := Smoke(&Config{}, SmokeConfig{}, make(chan os.Signal, 2))
+	assert.Equal(t, errSmokeCommand, err)
+
+	err = Smoke(&Config{}, SmokeConfig{Ready: "localhost:8080", Command: []string{"true"}}, make(chan os.Signal, 2))
+	assert.NotNil(t, err, "invalid ready probe")
+	assert.Contains(t, err.Error(), "invalid readiness probe")
+}
+
+func TestRunSmokeProgramExit(t *testing.T) {
+	mockBuilder := new(testdata.MockBuilder)
+	mockBuilder.On("Build").Return(nil)
+
+	mockRunner := new(testdata.MockRunner)
+	runnerErrorsChan := make(chan error)
+	mockRunner.On("Run").Return(&exec.Cmd{}, nil)
+	mockRunner.On("Errors").Return(runnerErrorsChan)
+	mockRunner.On("ExitStatus").Return(1)
+
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		runnerErrorsChan <- errors.New("exit status 1")
+	}()
+
+	svc := &service{builder: mockBuilder, runner: mockRunner}
+	smokeCfg := SmokeConfig{Ready: "tcp://localhost:1", ReadyTimeout: 10000, Command: []string{"true"}}
+	err := runSmoke([]*service{svc}, smokeCfg, make(chan os.Signal, 2))
+	assert.NotNil(t, err, "program exit error")
+	assert.Contains(t, err.Error(), "program exited with code 1")
+	assert.Contains(t, err.Error(), "before being ready")
+}
+
+func TestSmokeOutputDump(t *testing.T) {
+	output := &smokeOutput{}
+	dump := bytes.NewBufferString("")
+
+	output.dump(dump)
+	assert.Equal(t, "", dump.String())
+
+	output.Write([]byte("first line\n"))  // nolint errcheck
+	output.Write([]byte("second line\n")) // nolint errcheck
+	output.dump(dump)
+	assert.Equal(t, "first line\nsecond line\n", dump.String())
+}
+
+func TestSmokeOutputLimit(t *testing.T) {
+	defaultMaxSmokeOutput := maxSmokeOutput
+	maxSmokeOutput = 8
+	defer func() { maxSmokeOutput = defaultMaxSmokeOutput }()
+
+	output := &smokeOutput{}
+	dump := bytes.NewBufferString("")
+
+	output.Write([]byte("first\n"))  // nolint errcheck
+	output.Write([]byte("second\n")) // nolint errcheck
+	assert.True(t, output.truncated)
+
+	output.dump(dump)
+	assert.Equal(t, "\nsecond\n", dump.String())
+}
@@ -0,0 +1,215 @@
+package gaper
+
+import (
+	"fmt"
+	"os/exec"
+	"sync"
+	"time"
+)
+
+// reasons a build and restart cycle is started
+const (
+	CycleTriggerStart      = "start"
+	CycleTriggerChange     = "change"
+	CycleTriggerExit       = "exit"
+	CycleTriggerDependency = "dependency"
+	CycleTriggerCommand    = "command"
+)
+
+// DefaultHistorySize is the default number of cycles kept in the history
+var DefaultHistorySize = 100
+
+// Cycle contains the statistics of a build and restart cycle of a program
+type Cycle struct {
+	// name of the service, empty when there are no services
+	Service string
+	// what started the cycle, one of the CycleTrigger values
+	Trigger string
+	// changed files which triggered the cycle
+	Files     []string
+	StartedAt time.Time
+	// time spent building the program
+	BuildDuration time.Duration
+	BuildError    error
+	// time from the beginning of the cycle until the program is running
+	Duration         time.Duration
+	PID              int
+	ProgramStartedAt time.Time
+	// time from the program start until its readiness probe succeeds,
+	// zero if there is no probe or it has not succeeded yet
+	ReadyDuration time.Duration
+	Exited        bool
+	ExitCode      int
+	// details of the exit, only set once the program exited
+	Exit     ExitInfo
+	ExitedAt time.Time
+	// publishes the build events of the cycle
+	events *eventBus
+	// reports the diagnostics of the build
+	diagnostics *diagnosticsReporter
+}
+
+// newCycle begins a new cycle for a service
+func newCycle(service string, trigger string, files []string) *Cycle {
+	return &Cycle{Service: service, Trigger: trigger, Files: files, StartedAt: time.Now()}
+}
+
+// build builds the program measuring how long it takes
+func (c *Cycle) build(builder Builder) error {
+	c.events.publish(Event{Type: EventBuildStart, Service: c.Service})
+
+	start := time.Now()
+	err := builder.Build()
+	c.BuildDuration = time.Since(start)
+	c.BuildError = err
+	c.diagnostics.report(c.Service, err)
+
+	if err != nil {
+		event := Event{Type: EventBuildFailed, Service: c.Service, Error: err.Error(), Duration: c.BuildDuration}
+		if buildErr, ok := err.(*BuildError); ok {
+			event.Diagnostics = buildErr.Diagnostics
+		}
+		c.events.publish(event)
+	} else {
+		c.events.publish(Event{Type: EventBuildSuccess, Service: c.Service, Duration: c.BuildDuration})
+	}
+
+	return err
+}
+
+// started records the program started by the cycle
+func (c *Cycle) started(cmd *exec.Cmd) {
+	c.ProgramStartedAt = time.Now()
+	c.Duration = c.ProgramStartedAt.Sub(c.StartedAt)
+	if cmd != nil && cmd.Process != nil {
+		c.PID = cmd.Process.Pid
+	}
+}
+
+// Summary describes the cycle in a single line (e.g. "rebuilt in 2.1s (3 files changed)")
+func (c *Cycle) Summary() string {
+	duration := roundDuration(c.Duration)
+
+	switch c.Trigger {
+	case CycleTriggerStart:
+		return fmt.Sprintf("started in %v", duration)
+	case CycleTriggerChange:
+		files := "files"
+		if len(c.Files) == 1 {
+			files = "file"
+		}
+		return fmt.Sprintf("rebuilt in %v (%d %s changed)", duration, len(c.Files), files)
+	case CycleTriggerExit:
+		return fmt.Sprintf("rebuilt in %v (program exited)", duration)
+	case CycleTriggerDependency:
+		return fmt.Sprintf("rebuilt in %v (dependency restarted)", duration)
+	case CycleTriggerCommand:
+		return fmt.Sprintf("rebuilt in %v (requested)", duration)
+	}
+
+	return fmt.Sprintf("rebuilt in %v", duration)
+}
+
+// roundDuration rounds a duration to be easily read on the summaries,
+// keeping the milliseconds only for durations under a tenth of a second
+func roundDuration(d time.Duration) time.Duration {
+	if rounded := d.Round(100 * time.Millisecond); rounded != 0 {
+		return rounded
+	}
+
+	return d.Round(time.Millisecond)
+}
+
+// History keeps the latest build and restart cycles in memory
+type History struct {
+	mu     sync.Mutex
+	size   int
+	cycles []*Cycle
+	// latest exit of each service, kept even once its cycle is dropped
+	exits map[string]ExitInfo
+}
+
+// NewHistory creates a history keeping up to size cycles,
+// a size of 0 or less keeps DefaultHistorySize cycles
+func NewHistory(size int) *History {
+	if size <= 0 {
+		size = DefaultHistorySize
+	}
+
+	return &History{size: size, exits: map[string]ExitInfo{}}
+}
+
+// Cycles returns a copy of the cycles in the history, the oldest first
+func (h *History) Cycles() []Cycle {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	cycles := make([]Cycle, len(h.cycles))
+	for i, c := range h.cycles {
+		cycles[i] = *c
+	}
+
+	return cycles
+}
+
+// Last returns a copy of the latest cycle of a service
+func (h *History) Last(service string) (Cycle, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for i := len(h.cycles) - 1; i >= 0; i-- {
+		if h.cycles[i].Service == service {
+			return *h.cycles[i], true
+		}
+	}
+
+	return Cycle{}, false
+}
+
+// LastExit returns the latest exit of a service
+func (h *History) LastExit(service string) (ExitInfo, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	exit, ok := h.exits[service]
+	return exit, ok
+}
+
+// add appends a cycle dropping the oldest one once the history is full,
+// the cycle must only be changed through update afterwards
+func (h *History) add(c *Cycle) {
+	if h == nil {
+		return
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.cycles = append(h.cycles, c)
+	if len(h.cycles) > h.size {
+		h.cycles = h.cycles[len(h.cycles)-h.size:]
+	}
+}
+
+// addExit keeps the exit as the latest one of its service
+func (h *History) addExit(exit ExitInfo) {
+	if h == nil {
+		return
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.exits[exit.Service] = exit
+}
+
+// update changes a cycle which may be read concurrently from the history
+func (h *History) update(c *Cycle, fn func(c *Cycle)) {
+	if h == nil {
+		fn(c)
+		return
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	fn(c)
+}
@@ -0,0 +1,105 @@
+package gaper
+
+import (
+	"errors"
+	"os/exec"
+	"testing"
+	"time"
+
+	"github.com/maxcnunes/gaper/testdata"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCycleSummary(t *testing.T) {
+	testCases := []struct {
+		name     string
+		cycle    Cycle
+		expected string
+	}{
+		{
+			name:     "start",
+			cycle:    Cycle{Trigger: CycleTriggerStart, Duration: 1234 * time.Millisecond},
+			expected: "started in 1.2s",
+		},
+		{
+			name:     "many files changed",
+			cycle:    Cycle{Trigger: CycleTriggerChange, Duration: 2100 * time.Millisecond, Files: []string{"a.go", "b.go", "c.go"}},
+			expected: "rebuilt in 2.1s (3 files changed)",
+		},
+		{
+			name:     "single file changed",
+			cycle:    Cycle{Trigger: CycleTriggerChange, Duration: 500 * time.Millisecond, Files: []string{"a.go"}},
+			expected: "rebuilt in 500ms (1 file changed)",
+		},
+		{
+			name:     "short duration",
+			cycle:    Cycle{Trigger: CycleTriggerExit, Duration: 12 * time.Millisecond},
+			expected: "rebuilt in 12ms (program exited)",
+		},
+		{
+			name:     "dependency",
+			cycle:    Cycle{Trigger: CycleTriggerDependency, Duration: time.Second},
+			expected: "rebuilt in 1s (dependency restarted)",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, tc.cycle.Summary())
+		})
+	}
+}
+
+func TestCycleBuild(t *testing.T) {
+	mockBuilder := new(testdata.MockBuilder)
+	mockBuilder.On("Build").Return(errors.New("build-error"))
+
+	c := newCycle("api", CycleTriggerChange, []string{"main.go"})
+	err := c.build(mockBuilder)
+	assert.NotNil(t, err, "build error")
+	assert.Equal(t, err, c.BuildError)
+	assert.Equal(t, "api", c.Service)
+	assert.Equal(t, []string{"main.go"}, c.Files)
+	assert.False(t, c.StartedAt.IsZero())
+
+	c.started(&exec.Cmd{})
+	assert.Equal(t, 0, c.PID)
+	assert.True(t, c.Duration >= c.BuildDuration)
+}
+
+func TestHistory(t *testing.T) {
+	h := NewHistory(2)
+
+	h.add(&Cycle{Service: "api", Trigger: CycleTriggerStart})
+	h.add(&Cycle{Service: "worker", Trigger: CycleTriggerStart})
+	last := &Cycle{Service: "api", Trigger: CycleTriggerChange}
+	h.add(last)
+
+	cycles := h.Cycles()
+	assert.Len(t, cycles, 2)
+	assert.Equal(t, "worker", cycles[0].Service)
+	assert.Equal(t, CycleTriggerChange, cycles[1].Trigger)
+
+	h.update(last, func(c *Cycle) {
+		c.Exited = true
+		c.ExitCode = 2
+	})
+
+	c, ok := h.Last("api")
+	assert.True(t, ok)
+	assert.True(t, c.Exited)
+	assert.Equal(t, 2, c.ExitCode)
+
+	// changing the returned copies doesn't change the history
+	cycles[1].ExitCode = 3
+	c, _ = h.Last("api")
+	assert.Equal(t, 2, c.ExitCode)
+
+	_, ok = h.Last("docs")
+	assert.False(t, ok)
+}
+
+func TestHistoryDefaultSize(t *testing.T) {
+	h := NewHistory(0)
+	assert.Equal(t, DefaultHistorySize, h.size)
+}
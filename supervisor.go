@@ -0,0 +1,179 @@
+package gaper
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+)
+
+// errSupervisorStarted is returned on starting a supervisor more than once
+var errSupervisorStarted = errors.New("supervisor already started")
+
+// Supervisor builds, runs and restarts the programs from a config. Unlike Run,
+// it doesn't handle OS signals nor reads the keyboard, so it can be embedded in
+// other Go programs and stopped through a context or Stop.
+type Supervisor struct {
+	cfg      *Config
+	commands chan Command
+	signals  chan os.Signal
+	events   *eventBus
+
+	mu      sync.Mutex
+	started bool
+	done    chan struct{}
+}
+
+// New creates a supervisor for the programs from the config
+func New(cfg *Config) *Supervisor {
+	if cfg.Commands == nil {
+		cfg.Commands = make(chan Command)
+	}
+
+	if cfg.History == nil {
+		cfg.History = NewHistory(0)
+	}
+
+	cfg.events = newEventBus()
+	cfg.signals = make(chan os.Signal)
+
+	return &Supervisor{
+		cfg:      cfg,
+		commands: cfg.Commands,
+		signals:  cfg.signals,
+		events:   cfg.events,
+		done:     make(chan struct{}),
+	}
+}
+
+// Start builds and runs the programs, restarting them on file changes and exits,
+// until the context is canceled or Stop is called. It returns an error if the
+// programs couldn't be built or started, or if the watching failed.
+func (s *Supervisor) Start(ctx context.Context) error {
+	return s.run(ctx, nil)
+}
+
+// Stop kills the programs and makes Start return
+func (s *Supervisor) Stop() {
+	s.send(CommandQuit)
+}
+
+// Restart rebuilds and restarts all programs
+func (s *Supervisor) Restart() {
+	s.send(CommandRestart)
+}
+
+// Signal sends the signal to the running programs, or to their process groups
+// when Config.SignalProcessGroup is set, without restarting them
+func (s *Supervisor) Signal(sig os.Signal) {
+	if !s.isStarted() {
+		return
+	}
+
+	select {
+	case s.signals <- sig:
+	case <-s.done:
+	}
+}
+
+// Subscribe returns a channel receiving the events published from now on and
+// a function to stop receiving them. Events are dropped if the channel is not
+// read fast enough.
+func (s *Supervisor) Subscribe() (<-chan Event, func()) {
+	ch := s.events.subscribe()
+	return ch, func() { s.events.unsubscribe(ch) }
+}
+
+// History returns the statistics of the latest build and restart cycles
+func (s *Supervisor) History() *History {
+	return s.cfg.History
+}
+
+// send sends a command to the run loop, doing nothing if it is not running
+func (s *Supervisor) send(cmd Command) {
+	if !s.isStarted() {
+		return
+	}
+
+	select {
+	case s.commands <- cmd:
+	case <-s.done:
+	}
+}
+
+// isStarted checks if the supervisor has been started
+func (s *Supervisor) isStarted() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.started
+}
+
+// run sets up the programs and supervises them until the context is canceled,
+// a quit command or an OS signal is received
+func (s *Supervisor) run(ctx context.Context, chOSSiginal chan os.Signal) error {
+	s.mu.Lock()
+	if s.started {
+		s.mu.Unlock()
+		return errSupervisorStarted
+	}
+	s.started = true
+	s.mu.Unlock()
+	defer close(s.done)
+
+	cfg := s.cfg
+	if err := setupConfig(cfg); err != nil {
+		return err
+	}
+
+	logger.Debugf("Config: %+v", cfg)
+
+	wCfg := WatcherConfig{
+		DefaultIgnore:    !cfg.DisableDefaultIgnore,
+		PollInterval:     cfg.PollInterval,
+		WatchItems:       watchItems(cfg),
+		IgnoreItems:      cfg.IgnoreItems,
+		Extensions:       cfg.Extensions,
+		PauseFiles:       cfg.PauseFiles,
+		DisableGitPause:  cfg.DisableGitPause,
+		WorkingDirectory: cfg.WorkingDirectory,
+	}
+
+	if cfg.Cover {
+		if err := resetCoverage(cfg.CoverDir); err != nil {
+			return err
+		}
+	}
+
+	services, err := newServices(cfg)
+	if err != nil {
+		return err
+	}
+
+	// files are not watched when running the programs once
+	var watcher Watcher = &noopWatcher{}
+	if !cfg.Once {
+		if watcher, err = NewWatcher(wCfg); err != nil {
+			return fmt.Errorf("watcher error: %v", err)
+		}
+	}
+
+	if cfg.ControlAddr != "" {
+		control, err := startControlServer(cfg.ControlAddr, s.commands, cfg.History, s.events, watcher)
+		if err != nil {
+			return err
+		}
+		defer control.Close() // nolint errcheck
+	}
+
+	err = runServices(ctx, cfg, chOSSiginal, services, watcher)
+
+	// the programs have been stopped, so their coverage data is complete
+	if cfg.Cover {
+		if errCover := mergeCoverage(cfg.CoverDir, cfg.WorkingDirectory); errCover != nil {
+			logger.Error("Error merging coverage:", errCover)
+		}
+	}
+
+	return err
+}
@@ -0,0 +1,112 @@
+package gaper
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// waitEvent waits for an event of the given type skipping any other event
+func waitEvent(t *testing.T, events <-chan Event, eventType EventType) Event {
+	timeout := time.After(30 * time.Second)
+	for {
+		select {
+		case e := <-events:
+			if e.Type == eventType {
+				return e
+			}
+		case <-timeout:
+			assert.Fail(t, "timeout waiting for event", string(eventType))
+			return Event{}
+		}
+	}
+}
+
+func TestSupervisorStartCancel(t *testing.T) {
+	s := New(&Config{BuildPath: filepath.Join("testdata", "server")})
+	events, unsubscribe := s.Subscribe()
+	defer unsubscribe()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errs := make(chan error)
+	go func() {
+		errs <- s.Start(ctx)
+	}()
+
+	waitEvent(t, events, EventBuildStart)
+	waitEvent(t, events, EventBuildSuccess)
+	started := waitEvent(t, events, EventStart)
+	assert.NotZero(t, started.PID)
+
+	s.Restart()
+	waitEvent(t, events, EventBuildSuccess)
+	restarted := waitEvent(t, events, EventStart)
+	assert.NotEqual(t, started.PID, restarted.PID)
+
+	cancel()
+	assert.Nil(t, <-errs, "start error")
+
+	cycles := s.History().Cycles()
+	assert.Len(t, cycles, 2)
+	assert.Equal(t, CycleTriggerCommand, cycles[1].Trigger)
+}
+
+func TestSupervisorZeroPollInterval(t *testing.T) {
+	cfg := &Config{BuildPath: filepath.Join("testdata", "server"), PollInterval: 0}
+	s := New(cfg)
+	events, unsubscribe := s.Subscribe()
+	defer unsubscribe()
+
+	errs := make(chan error)
+	go func() {
+		errs <- s.Start(context.Background())
+	}()
+
+	waitEvent(t, events, EventStart)
+	assert.Equal(t, DefaultPoolInterval, cfg.PollInterval)
+
+	s.Stop()
+	assert.Nil(t, <-errs, "start error")
+}
+
+func TestSupervisorStop(t *testing.T) {
+	s := New(&Config{BuildPath: filepath.Join("testdata", "server")})
+	events, unsubscribe := s.Subscribe()
+	defer unsubscribe()
+
+	// stopping a supervisor not started doesn't block
+	s.Stop()
+
+	errs := make(chan error)
+	go func() {
+		errs <- s.Start(context.Background())
+	}()
+
+	waitEvent(t, events, EventStart)
+	s.Stop()
+	assert.Nil(t, <-errs, "start error")
+
+	// stopping a supervisor already stopped doesn't block
+	s.Stop()
+
+	err := s.Start(context.Background())
+	assert.Equal(t, errSupervisorStarted, err)
+}
+
+func TestSupervisorBuildError(t *testing.T) {
+	s := New(&Config{BuildPath: filepath.Join("testdata", "build-failure")})
+	events, unsubscribe := s.Subscribe()
+	defer unsubscribe()
+
+	errs := make(chan error)
+	go func() {
+		errs <- s.Start(context.Background())
+	}()
+
+	failed := waitEvent(t, events, EventBuildFailed)
+	assert.NotEmpty(t, failed.Error)
+	assert.NotNil(t, <-errs, "build error")
+}
@@ -0,0 +1,15 @@
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+func main() {
+	if len(os.Args) > 1 {
+		fmt.Println("Covered with args")
+		return
+	}
+
+	fmt.Println("Covered")
+}
@@ -1,6 +1,7 @@
 package testdata
 
 import (
+	"os"
 	"os/exec"
 
 	"github.com/stretchr/testify/mock"
@@ -69,6 +70,12 @@ func (m *MockRunner) ExitStatus(err error) int {
 	return args.Int(0)
 }
 
+// Signal ...
+func (m *MockRunner) Signal(sig os.Signal) error {
+	args := m.Called(sig)
+	return args.Error(0)
+}
+
 // MockWacther ...
 type MockWacther struct {
 	mock.Mock
@@ -88,3 +95,19 @@ func (m *MockWacther) Errors() chan error {
 	args := m.Called()
 	return args.Get(0).(chan error)
 }
+
+// Pause ...
+func (m *MockWacther) Pause() {
+	m.Called()
+}
+
+// Resume ...
+func (m *MockWacther) Resume() {
+	m.Called()
+}
+
+// Paused ...
+func (m *MockWacther) Paused() bool {
+	args := m.Called()
+	return args.Bool(0)
+}
@@ -0,0 +1,8 @@
+package lib
+
+import "fmt"
+
+// Hello prints a greeting message
+func Hello() {
+	fmt.Println("Hello from lib")
+}
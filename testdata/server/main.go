@@ -1,6 +1,7 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	"html"
 	"log"
@@ -10,6 +11,9 @@ import (
 var Version string
 
 func main() {
+	addr := flag.String("addr", ":8080", "address the server listens on")
+	flag.Parse()
+
 	http.HandleFunc("/foo", func(w http.ResponseWriter, r *http.Request) {
 		fmt.Fprintf(w, "Hello, %q", html.EscapeString(r.URL.Path)) // nolint gas
 	})
@@ -19,5 +23,5 @@ func main() {
 	})
 
 	log.Println("Starting server: Version", Version)
-	log.Fatal(http.ListenAndServe(":8080", nil))
+	log.Fatal(http.ListenAndServe(*addr, nil))
 }
@@ -1,12 +1,12 @@
 package gaper
 
 import (
-	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
-	"regexp"
+	"sort"
 	"strings"
+	"sync"
 	"time"
 
 	zglob "github.com/mattn/go-zglob"
@@ -17,6 +17,9 @@ type Watcher interface {
 	Watch()
 	Errors() chan error
 	Events() chan string
+	Pause()
+	Resume()
+	Paused() bool
 }
 
 // watcher is a interface for the watch process
@@ -28,6 +31,16 @@ type watcher struct {
 	allowedExtensions map[string]bool
 	events            chan string
 	errors            chan error
+	// changes are only detected on files modified after this time
+	startTime time.Time
+	// while paused, by a call to Pause or by the existence of any of the
+	// pause files, changes are kept as pending and emitted on resuming
+	mu          sync.Mutex
+	paused      bool
+	pauseFiles  []string
+	pausedBy    string
+	pending     []string
+	pendingSeen map[string]bool
 }
 
 // WatcherConfig defines the settings available for the watcher
@@ -37,6 +50,14 @@ type WatcherConfig struct {
 	WatchItems    []string
 	IgnoreItems   []string
 	Extensions    []string
+	// files which pause the watching while they exist (e.g. ".git/index.lock")
+	PauseFiles []string
+	// don't pause the watching while a git operation is in progress
+	// (e.g. checkout, merge or rebase) on the current repository
+	DisableGitPause bool
+	// directory whose repository pauses the watching during git operations,
+	// the first watched path is used when empty
+	WorkingDirectory string
 }
 
 // NewWatcher creates a new watcher
@@ -49,10 +70,7 @@ func NewWatcher(cfg WatcherConfig) (Watcher, error) {
 		cfg.Extensions = DefaultExtensions
 	}
 
-	allowedExts := make(map[string]bool)
-	for _, ext := range cfg.Extensions {
-		allowedExts["."+ext] = true
-	}
+	allowedExts := extensionsMap(cfg.Extensions)
 
 	watchPaths, err := resolvePaths(cfg.WatchItems, allowedExts)
 	if err != nil {
@@ -64,6 +82,18 @@ func NewWatcher(cfg WatcherConfig) (Watcher, error) {
 		return nil, err
 	}
 
+	pauseFiles := cfg.PauseFiles
+	if !cfg.DisableGitPause {
+		dir, err := gitPauseDir(cfg.WorkingDirectory, watchPaths)
+		if err != nil {
+			return nil, err
+		}
+
+		if dir != "" {
+			pauseFiles = append(gitPauseFiles(dir), pauseFiles...)
+		}
+	}
+
 	logger.Debugf("Resolved watch paths: %v", watchPaths)
 	logger.Debugf("Resolved ignore paths: %v", ignorePaths)
 	return &watcher{
@@ -74,25 +104,57 @@ func NewWatcher(cfg WatcherConfig) (Watcher, error) {
 		watchItems:        watchPaths,
 		ignoreItems:       ignorePaths,
 		allowedExtensions: allowedExts,
+		startTime:         time.Now(),
+		pauseFiles:        pauseFiles,
+		pendingSeen:       map[string]bool{},
 	}, nil
 }
 
-var startTime = time.Now()
-var errDetectedChange = errors.New("done")
+// gitPauseDir returns the directory whose repository pauses the watching
+// during git operations, which is the working directory when set or else
+// the first watched path, empty when there is none
+func gitPauseDir(wd string, watchPaths map[string]bool) (string, error) {
+	if wd != "" {
+		return wd, nil
+	}
+
+	paths := make([]string, 0, len(watchPaths))
+	for path := range watchPaths {
+		paths = append(paths, path)
+	}
+
+	if len(paths) == 0 {
+		return "", nil
+	}
+
+	sort.Strings(paths)
+	return filepath.Abs(paths[0])
+}
 
 // Watch starts watching for file changes
 func (w *watcher) Watch() {
 	for {
+		scanTime := time.Now()
+
+		var filesChanged []string
 		for watchPath := range w.watchItems {
-			fileChanged, err := w.scanChange(watchPath)
+			changes, err := w.scanChanges(watchPath)
 			if err != nil {
 				w.errors <- err
 				return
 			}
 
-			if fileChanged != "" {
+			filesChanged = append(filesChanged, changes...)
+		}
+
+		if len(filesChanged) > 0 {
+			w.startTime = scanTime
+		}
+
+		w.addPending(filesChanged)
+		if !w.checkPaused() {
+			for _, fileChanged := range w.takePending() {
 				w.events <- fileChanged
-				startTime = time.Now()
 			}
 		}
 
@@ -100,6 +162,84 @@ func (w *watcher) Watch() {
 	}
 }
 
+// Pause stops emitting the file changes until Resume is called
+func (w *watcher) Pause() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.paused = true
+}
+
+// Resume emits the changes detected while paused, which happens
+// on the next scan, and keeps emitting the next ones
+func (w *watcher) Resume() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.paused = false
+}
+
+// Paused checks if the watcher is paused by a call to Pause or by a pause file
+func (w *watcher) Paused() bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.paused || w.pausedBy != ""
+}
+
+// checkPaused updates the pause state from the pause files logging when it changes
+func (w *watcher) checkPaused() bool {
+	pausedBy := ""
+	for _, file := range w.pauseFiles {
+		if _, err := os.Stat(file); err == nil {
+			pausedBy = file
+			break
+		}
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if pausedBy != w.pausedBy {
+		// the git index lock exists briefly on most git commands (e.g. git status),
+		// so the pauses caused by it are only reported in debug
+		log := logger.Info
+		if filepath.Base(pausedBy) == gitIndexLock || filepath.Base(w.pausedBy) == gitIndexLock {
+			log = logger.Debug
+		}
+
+		if pausedBy != "" {
+			log("Paused watching for changes while " + pausedBy + " exists")
+		} else {
+			log("Resumed watching for changes since " + w.pausedBy + " has been removed")
+		}
+		w.pausedBy = pausedBy
+	}
+
+	return w.paused || w.pausedBy != ""
+}
+
+// addPending keeps the changed files not emitted yet
+func (w *watcher) addPending(files []string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	for _, file := range files {
+		if !w.pendingSeen[file] {
+			w.pendingSeen[file] = true
+			w.pending = append(w.pending, file)
+		}
+	}
+}
+
+// takePending returns the changed files not emitted yet clearing them
+func (w *watcher) takePending() []string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	pending := w.pending
+	w.pending = nil
+	w.pendingSeen = map[string]bool{}
+	return pending
+}
+
 // Events get events occurred during the watching
 // these events are emitted only a file changing is detected
 func (w *watcher) Events() chan string {
@@ -111,10 +251,10 @@ func (w *watcher) Errors() chan error {
 	return w.errors
 }
 
-func (w *watcher) scanChange(watchPath string) (string, error) {
+func (w *watcher) scanChanges(watchPath string) ([]string, error) {
 	logger.Debug("Watching ", watchPath)
 
-	var fileChanged string
+	var filesChanged []string
 
 	err := filepath.Walk(watchPath, func(path string, info os.FileInfo, err error) error {
 		if err != nil {
@@ -130,20 +270,25 @@ func (w *watcher) scanChange(watchPath string) (string, error) {
 			return skipFile(info)
 		}
 
+		// nested modules are not part of the watched module,
+		// they are only watched if they are a watch path themselves
+		if info.IsDir() && path != watchPath && isModuleRoot(path) {
+			return filepath.SkipDir
+		}
+
 		ext := filepath.Ext(path)
-		if _, ok := w.allowedExtensions[ext]; ok && info.ModTime().After(startTime) {
-			fileChanged = path
-			return errDetectedChange
+		if _, ok := w.allowedExtensions[ext]; ok && info.ModTime().After(w.startTime) {
+			filesChanged = append(filesChanged, path)
 		}
 
 		return nil
 	})
 
-	if err != nil && err != errDetectedChange {
-		return "", err
+	if err != nil {
+		return nil, err
 	}
 
-	return fileChanged, nil
+	return filesChanged, nil
 }
 
 func (w *watcher) ignoreFile(path string, info os.FileInfo) bool {
@@ -155,8 +300,9 @@ func (w *watcher) ignoreFile(path string, info os.FileInfo) bool {
 
 	// check if preset ignore is enabled
 	if w.defaultIgnore {
-		// check for hidden files and directories
-		if name := info.Name(); name[0] == '.' && name != "." {
+		// check for hidden files and directories, the current and parent
+		// directories are only named as such when they are watch paths
+		if name := info.Name(); name[0] == '.' && name != "." && name != ".." {
 			return true
 		}
 
@@ -216,27 +362,23 @@ func resolvePaths(paths []string, extensions map[string]bool) (map[string]bool,
 
 // remove overlapped paths so it makes the scan for changes later faster and simpler
 func removeOverlappedPaths(mapPaths map[string]bool) {
-	startDot := regexp.MustCompile(`^\./`)
-
 	for p1 := range mapPaths {
-		p1 = startDot.ReplaceAllString(p1, "")
-
 		// skip to next item if this path has already been checked
-		if v, ok := mapPaths[p1]; ok && !v {
+		if !mapPaths[p1] {
 			continue
 		}
 
 		for p2 := range mapPaths {
-			p2 = startDot.ReplaceAllString(p2, "")
-
-			if p1 == p2 {
+			if p1 == p2 || !mapPaths[p2] {
 				continue
 			}
 
-			if strings.HasPrefix(p2, p1) {
+			// nested modules are kept since they are not scanned by their parent path
+			if filepath.Clean(p1) == filepath.Clean(p2) || (isSubPath(p1, p2) && !isModuleRoot(p2)) {
 				mapPaths[p2] = false
-			} else if strings.HasPrefix(p1, p2) {
+			} else if isSubPath(p2, p1) && !isModuleRoot(p1) {
 				mapPaths[p1] = false
+				break
 			}
 		}
 	}
@@ -249,9 +391,53 @@ func removeOverlappedPaths(mapPaths map[string]bool) {
 	}
 }
 
+// isSubPath checks if child is the same or is inside the parent path
+func isSubPath(parent string, child string) bool {
+	rel, err := filepath.Rel(filepath.Clean(parent), filepath.Clean(child))
+	if err != nil {
+		return false
+	}
+
+	return rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// extensionsMap converts a list of extensions to a lookup map of
+// extensions in the same format returned by filepath.Ext
+func extensionsMap(extensions []string) map[string]bool {
+	result := make(map[string]bool)
+	for _, ext := range extensions {
+		result["."+ext] = true
+	}
+
+	return result
+}
+
 func skipFile(info os.FileInfo) error {
 	if info.IsDir() {
 		return filepath.SkipDir
 	}
 	return nil
 }
+
+// noopWatcher is used when the files are not watched, it never emits any change
+type noopWatcher struct {
+	paused bool
+}
+
+// Watch does nothing
+func (w *noopWatcher) Watch() {}
+
+// Events returns a nil channel, which never receives any event
+func (w *noopWatcher) Events() chan string { return nil }
+
+// Errors returns a nil channel, which never receives any error
+func (w *noopWatcher) Errors() chan error { return nil }
+
+// Pause only keeps the pause state
+func (w *noopWatcher) Pause() { w.paused = true }
+
+// Resume only keeps the pause state
+func (w *noopWatcher) Resume() { w.paused = false }
+
+// Paused checks if it is paused
+func (w *noopWatcher) Paused() bool { return w.paused }
@@ -1,6 +1,7 @@
 package gaper
 
 import (
+	"io/ioutil"
 	"os"
 	"path/filepath"
 	"runtime"
@@ -131,6 +132,12 @@ func TestWatcherIgnoreFile(t *testing.T) {
 		name, file, ignoreFile      string
 		defaultIgnore, expectIgnore bool
 	}{
+		{
+			name:          "with default ignore enabled it does not ignore the parent directory",
+			file:          "..",
+			defaultIgnore: true,
+			expectIgnore:  false,
+		},
 		{
 			name:          "with default ignore enabled it ignores vendor folder",
 			file:          "vendor",
@@ -245,6 +252,24 @@ func TestWatcherResolvePaths(t *testing.T) {
 			extensions:  map[string]bool{".txt": true},
 			expectPaths: map[string]bool{"testdata/test-duplicated-paths": true},
 		},
+		{
+			name:        "keep paths outside of the current directory",
+			paths:       []string{".", "../lib"},
+			extensions:  map[string]bool{".go": true},
+			expectPaths: map[string]bool{".": true, "../lib": true},
+		},
+		{
+			name:        "keep nested modules",
+			paths:       []string{"testdata/modules", "testdata/modules/app"},
+			extensions:  map[string]bool{".go": true},
+			expectPaths: map[string]bool{"testdata/modules": true, "testdata/modules/app": true},
+		},
+		{
+			name:        "keep paths sharing the same prefix",
+			paths:       []string{"testdata/server", "testdata/server-2"},
+			extensions:  map[string]bool{".go": true},
+			expectPaths: map[string]bool{"testdata/server": true, "testdata/server-2": true},
+		},
 	}
 
 	for _, tc := range testCases {
@@ -259,3 +284,137 @@ func TestWatcherResolvePaths(t *testing.T) {
 		})
 	}
 }
+
+func TestWatcherSkipNestedModules(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gaper-modules")
+	assert.Nil(t, err, "temp dir error")
+	defer os.RemoveAll(dir) // nolint errcheck
+
+	libdir := filepath.Join(dir, "lib")
+	assert.Nil(t, os.Mkdir(libdir, 0755), "mkdir error")
+	err = ioutil.WriteFile(filepath.Join(libdir, "go.mod"), []byte("module example.com/lib\n"), 0644)
+	assert.Nil(t, err, "write error")
+
+	wCfg := WatcherConfig{
+		DefaultIgnore: true,
+		WatchItems:    []string{dir},
+		Extensions:    []string{"go"},
+	}
+	wt, err := NewWatcher(wCfg)
+	assert.Nil(t, err, "wacher error")
+	w := wt.(*watcher)
+
+	libfile := filepath.Join(libdir, "lib.go")
+	err = ioutil.WriteFile(libfile, []byte("package lib\n"), 0644)
+	assert.Nil(t, err, "write error")
+	changeTime := time.Now().Add(time.Second)
+	err = os.Chtimes(libfile, changeTime, changeTime)
+	assert.Nil(t, err, "chtimes error")
+
+	filesChanged, err := w.scanChanges(dir)
+	assert.Nil(t, err, "scan error")
+	assert.Len(t, filesChanged, 0)
+
+	filesChanged, err = w.scanChanges(libdir)
+	assert.Nil(t, err, "scan error")
+	assert.Equal(t, []string{libfile}, filesChanged)
+}
+
+func newPauseTestWatcher(t *testing.T) (Watcher, string, func()) {
+	dir, err := ioutil.TempDir("", "gaper-pause")
+	assert.Nil(t, err, "temp dir error")
+
+	for _, name := range []string{"a.go", "b.go"} {
+		err = ioutil.WriteFile(filepath.Join(dir, name), []byte("package a\n"), 0644)
+		assert.Nil(t, err, "write error")
+	}
+
+	w, err := NewWatcher(WatcherConfig{
+		PollInterval: 50,
+		WatchItems:   []string{dir},
+		Extensions:   []string{"go"},
+		PauseFiles:   []string{filepath.Join(dir, "index.lock")},
+		// the pause is only checked against the test files
+		DisableGitPause: true,
+	})
+	assert.Nil(t, err, "wacher error")
+
+	return w, dir, func() { os.RemoveAll(dir) } // nolint errcheck
+}
+
+// touchPaused changes the files and checks no event is emitted
+func touchPaused(t *testing.T, w Watcher, files ...string) {
+	for _, file := range files {
+		time.Sleep(100 * time.Millisecond)
+		err := os.Chtimes(file, time.Now(), time.Now())
+		assert.Nil(t, err, "chtimes error")
+	}
+
+	select {
+	case event := <-w.Events():
+		assert.Fail(t, "unexpected event while paused", event)
+	case <-time.After(300 * time.Millisecond):
+	}
+}
+
+func TestWatcherPause(t *testing.T) {
+	w, dir, cleanup := newPauseTestWatcher(t)
+	defer cleanup()
+
+	w.Pause()
+	assert.True(t, w.Paused())
+	go w.Watch()
+
+	fileA, fileB := filepath.Join(dir, "a.go"), filepath.Join(dir, "b.go")
+	touchPaused(t, w, fileA, fileB, fileA)
+
+	w.Resume()
+	assert.False(t, w.Paused())
+	assert.Equal(t, fileA, <-w.Events())
+	assert.Equal(t, fileB, <-w.Events())
+}
+
+func TestWatcherPauseFile(t *testing.T) {
+	w, dir, cleanup := newPauseTestWatcher(t)
+	defer cleanup()
+
+	lockFile := filepath.Join(dir, "index.lock")
+	err := ioutil.WriteFile(lockFile, nil, 0644)
+	assert.Nil(t, err, "write error")
+
+	go w.Watch()
+
+	fileA := filepath.Join(dir, "a.go")
+	touchPaused(t, w, fileA)
+	assert.True(t, w.Paused())
+
+	err = os.Remove(lockFile)
+	assert.Nil(t, err, "remove error")
+	assert.Equal(t, fileA, <-w.Events())
+	assert.False(t, w.Paused())
+}
+
+func TestWatcherGitPause(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gaper-git")
+	assert.Nil(t, err, "temp dir error")
+	defer os.RemoveAll(dir) // nolint errcheck
+
+	gitDir := filepath.Join(dir, gitDirName)
+	src := filepath.Join(dir, "src")
+	assert.Nil(t, os.MkdirAll(gitDir, os.ModePerm))
+	assert.Nil(t, os.MkdirAll(src, os.ModePerm))
+
+	// the repository is found from the working directory, not the one of the process
+	wt, err := NewWatcher(WatcherConfig{WatchItems: []string{"testdata"}, WorkingDirectory: dir})
+	assert.Nil(t, err, "wacher error")
+	assert.Contains(t, wt.(*watcher).pauseFiles, filepath.Join(gitDir, "MERGE_HEAD"))
+
+	// or from the watched paths without a working directory
+	wt, err = NewWatcher(WatcherConfig{WatchItems: []string{src}})
+	assert.Nil(t, err, "wacher error")
+	assert.Contains(t, wt.(*watcher).pauseFiles, filepath.Join(gitDir, "MERGE_HEAD"))
+
+	wt, err = NewWatcher(WatcherConfig{WatchItems: []string{src}, DisableGitPause: true})
+	assert.Nil(t, err, "wacher error")
+	assert.Empty(t, wt.(*watcher).pauseFiles)
+}